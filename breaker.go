@@ -0,0 +1,277 @@
+package httpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_CIRCUIT_FAILURE_RATIO      = 0.5
+	DEFAULT_CIRCUIT_MIN_REQUESTS       = 10
+	DEFAULT_CIRCUIT_WINDOW             = 60 * time.Second
+	DEFAULT_CIRCUIT_COOL_DOWN          = 30 * time.Second
+	DEFAULT_CIRCUIT_HALF_OPEN_REQUESTS = 1
+)
+
+// CircuitState state of a host's circuit breaker
+type CircuitState int
+
+const (
+	CIRCUIT_STATE_CLOSED    CircuitState = 0 //requests are sent and the results are counted
+	CIRCUIT_STATE_OPEN      CircuitState = 1 //requests are rejected with *CircuitOpenError until cool-down passed
+	CIRCUIT_STATE_HALF_OPEN CircuitState = 2 //limited probe requests are sent to check whether the host recovered
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CIRCUIT_STATE_CLOSED:
+		return "closed"
+	case CIRCUIT_STATE_OPEN:
+		return "open"
+	case CIRCUIT_STATE_HALF_OPEN:
+		return "half-open"
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
+// CircuitFailure decide whether the result of a request is a failure of host
+type CircuitFailure func(r *Response, err error) bool
+
+// CircuitStateHandler called when the circuit state of host changed
+type CircuitStateHandler func(strHost string, from, to CircuitState)
+
+// CircuitBreakerPolicy per host circuit breaker policy, zero value fields will be set to default
+type CircuitBreakerPolicy struct {
+	FailureRatio     float64             //open the circuit when failures/requests in window reaches it (default 0.5)
+	MinRequests      int                 //min requests in window before the failure ratio is checked (default 10)
+	Window           time.Duration       //the counters of closed circuit are reset every window (default 60s)
+	CoolDown         time.Duration       //duration of open state before probing the host (default 30s)
+	HalfOpenRequests int                 //max concurrent probe requests in half-open state (default 1)
+	IsFailure        CircuitFailure      //custom failure condition (default transport error or 5xx status)
+	OnStateChange    CircuitStateHandler //called without lock when the state of a host changed
+}
+
+// CircuitOpenError returned when the circuit of host is open, the request is not sent
+type CircuitOpenError struct {
+	Host       string        //host of the request
+	RetryAfter time.Duration //time to wait until the circuit becomes half-open
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit of host [%s] is open, retry after [%v]", e.Host, e.RetryAfter)
+}
+
+type circuitBreaker struct {
+	policy     CircuitBreakerPolicy
+	locker     sync.Mutex
+	circuit    map[string]*hostCircuit
+	generation uint64    //the last generation of all circuits, so a removed and recreated circuit never reuses a generation
+	lastSweep  time.Time //the idle circuits are removed at most once per window
+}
+
+type hostCircuit struct {
+	state      CircuitState
+	generation uint64 //increased on every state change and window reset, the results of old generation are ignored
+	requests   int
+	failures   int
+	inflight   int
+	expire     time.Time //end of window in closed state or end of cool-down in open state
+}
+
+// WithCircuitBreaker set per host circuit breaker policy of client, nil means disabled
+func (c *Client) WithCircuitBreaker(policy *CircuitBreakerPolicy) *Client {
+	c.locker.Lock()
+	c.breaker = newCircuitBreaker(policy)
+	c.locker.Unlock()
+	return c
+}
+
+// CircuitState returns the circuit state of host ("host:port" of url), it's always closed if circuit breaker disabled
+func (c *Client) CircuitState(strHost string) CircuitState {
+	c.locker.RLock()
+	breaker := c.breaker
+	c.locker.RUnlock()
+	if breaker == nil {
+		return CIRCUIT_STATE_CLOSED
+	}
+	return breaker.state(strHost)
+}
+
+// sendCircuit send request through the circuit breaker of request host
+func (c *Client) sendCircuit(ctx context.Context, strHost string, send func() (*Response, error)) (*Response, error) {
+	c.locker.RLock()
+	breaker := c.breaker
+	c.locker.RUnlock()
+	if breaker == nil {
+		return send()
+	}
+	generation, err := breaker.allow(strHost)
+	if err != nil {
+		return nil, err
+	}
+	r, err := send()
+	if ctx.Err() != nil || isRejectedError(err) { //canceled by caller or rate limited, not a failure of host
+		breaker.release(strHost, generation)
+	} else {
+		breaker.done(strHost, generation, breaker.policy.IsFailure(r, err))
+	}
+	return r, err
+}
+
+func newCircuitBreaker(policy *CircuitBreakerPolicy) *circuitBreaker {
+	if policy == nil {
+		return nil
+	}
+	p := *policy
+	if p.FailureRatio <= 0 || p.FailureRatio > 1 {
+		p.FailureRatio = DEFAULT_CIRCUIT_FAILURE_RATIO
+	}
+	if p.MinRequests <= 0 {
+		p.MinRequests = DEFAULT_CIRCUIT_MIN_REQUESTS
+	}
+	if p.Window <= 0 {
+		p.Window = DEFAULT_CIRCUIT_WINDOW
+	}
+	if p.CoolDown <= 0 {
+		p.CoolDown = DEFAULT_CIRCUIT_COOL_DOWN
+	}
+	if p.HalfOpenRequests <= 0 {
+		p.HalfOpenRequests = DEFAULT_CIRCUIT_HALF_OPEN_REQUESTS
+	}
+	if p.IsFailure == nil {
+		p.IsFailure = isCircuitFailure
+	}
+	return &circuitBreaker{
+		policy:  p,
+		circuit: make(map[string]*hostCircuit),
+	}
+}
+
+// isCircuitFailure the default failure condition, transport error or 5xx status
+func isCircuitFailure(r *Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return r != nil && r.StatusCode >= 500
+}
+
+func (b *circuitBreaker) state(strHost string) CircuitState {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	hc, ok := b.circuit[strings.ToLower(strHost)]
+	if !ok {
+		return CIRCUIT_STATE_CLOSED
+	}
+	return hc.state
+}
+
+// allow check whether the request to host could be sent and returns the generation of circuit
+func (b *circuitBreaker) allow(strHost string) (uint64, error) {
+	var now = time.Now()
+	var strKey = strings.ToLower(strHost)
+	b.locker.Lock()
+	if now.Sub(b.lastSweep) >= b.policy.Window {
+		b.lastSweep = now
+		b.sweep(now)
+	}
+	hc, ok := b.circuit[strKey]
+	if !ok {
+		hc = &hostCircuit{}
+		b.reset(hc, CIRCUIT_STATE_CLOSED, now.Add(b.policy.Window))
+		b.circuit[strKey] = hc
+	}
+	var from = hc.state
+	switch hc.state {
+	case CIRCUIT_STATE_CLOSED:
+		if now.After(hc.expire) {
+			b.reset(hc, CIRCUIT_STATE_CLOSED, now.Add(b.policy.Window))
+		}
+	case CIRCUIT_STATE_OPEN:
+		if now.Before(hc.expire) {
+			b.locker.Unlock()
+			return 0, &CircuitOpenError{Host: strHost, RetryAfter: hc.expire.Sub(now)}
+		}
+		b.reset(hc, CIRCUIT_STATE_HALF_OPEN, time.Time{})
+	}
+	if hc.state == CIRCUIT_STATE_HALF_OPEN && hc.inflight >= b.policy.HalfOpenRequests {
+		b.locker.Unlock()
+		b.notify(strHost, from, CIRCUIT_STATE_HALF_OPEN)
+		return 0, &CircuitOpenError{Host: strHost}
+	}
+	hc.inflight++
+	var to, generation = hc.state, hc.generation
+	b.locker.Unlock()
+	b.notify(strHost, from, to)
+	return generation, nil
+}
+
+// done count the result of request sent in the generation of circuit
+func (b *circuitBreaker) done(strHost string, generation uint64, failed bool) {
+	var now = time.Now()
+	b.locker.Lock()
+	hc, ok := b.circuit[strings.ToLower(strHost)]
+	if !ok || hc.generation != generation {
+		b.locker.Unlock()
+		return
+	}
+	hc.inflight--
+	var from = hc.state
+	switch hc.state {
+	case CIRCUIT_STATE_CLOSED:
+		hc.requests++
+		if failed {
+			hc.failures++
+		}
+		if hc.requests >= b.policy.MinRequests && float64(hc.failures)/float64(hc.requests) >= b.policy.FailureRatio {
+			b.reset(hc, CIRCUIT_STATE_OPEN, now.Add(b.policy.CoolDown))
+		}
+	case CIRCUIT_STATE_HALF_OPEN:
+		if failed {
+			b.reset(hc, CIRCUIT_STATE_OPEN, now.Add(b.policy.CoolDown))
+		} else {
+			b.reset(hc, CIRCUIT_STATE_CLOSED, now.Add(b.policy.Window))
+		}
+	}
+	var to = hc.state
+	b.locker.Unlock()
+	b.notify(strHost, from, to)
+}
+
+// release give back the slot of request without counting the result
+func (b *circuitBreaker) release(strHost string, generation uint64) {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	if hc, ok := b.circuit[strings.ToLower(strHost)]; ok && hc.generation == generation {
+		hc.inflight--
+	}
+}
+
+func (b *circuitBreaker) notify(strHost string, from, to CircuitState) {
+	if from != to && b.policy.OnStateChange != nil {
+		b.policy.OnStateChange(strHost, from, to)
+	}
+}
+
+// sweep remove the closed circuits which have no request in flight and passed their window, they are the same as
+// new circuits. the caller must hold the lock
+func (b *circuitBreaker) sweep(now time.Time) {
+	for k, hc := range b.circuit {
+		if hc.state == CIRCUIT_STATE_CLOSED && hc.inflight == 0 && now.After(hc.expire) {
+			delete(b.circuit, k)
+		}
+	}
+}
+
+// reset change the state of circuit and start a new generation, the caller must hold the lock
+func (b *circuitBreaker) reset(hc *hostCircuit, state CircuitState, expire time.Time) {
+	b.generation++
+	hc.state = state
+	hc.generation = b.generation
+	hc.requests = 0
+	hc.failures = 0
+	hc.inflight = 0
+	hc.expire = expire
+}
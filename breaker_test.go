@@ -0,0 +1,130 @@
+package httpc
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	const strHost = "api.example.com:443"
+	var changes []CircuitState
+	b := newCircuitBreaker(&CircuitBreakerPolicy{
+		FailureRatio: 0.5,
+		MinRequests:  4,
+		CoolDown:     50 * time.Millisecond,
+		OnStateChange: func(host string, from, to CircuitState) {
+			changes = append(changes, to)
+		},
+	})
+	send := func(failed bool) error {
+		generation, err := b.allow(strHost)
+		if err != nil {
+			return err
+		}
+		b.done(strHost, generation, failed)
+		return nil
+	}
+	for i, failed := range []bool{true, false, false, true} { //2 of 4 failed reaches ratio 0.5
+		if err := send(failed); err != nil {
+			t.Fatalf("closed circuit request [%d] error [%s]", i, err)
+		}
+	}
+	if s := b.state(strHost); s != CIRCUIT_STATE_OPEN {
+		t.Fatalf("state [%s] after failures, expect open", s)
+	}
+	var openErr *CircuitOpenError
+	if err := send(false); !errors.As(err, &openErr) || openErr.RetryAfter <= 0 {
+		t.Fatalf("open circuit error [%v], expect *CircuitOpenError with retry after", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	generation, err := b.allow(strHost) //the first probe after cool-down
+	if err != nil {
+		t.Fatalf("half-open probe error [%s]", err)
+	}
+	if s := b.state(strHost); s != CIRCUIT_STATE_HALF_OPEN {
+		t.Fatalf("state [%s] after cool-down, expect half-open", s)
+	}
+	if _, err = b.allow(strHost); !errors.As(err, &openErr) {
+		t.Fatalf("second half-open probe error [%v], expect *CircuitOpenError", err)
+	}
+	b.done(strHost, generation, true)
+	if s := b.state(strHost); s != CIRCUIT_STATE_OPEN {
+		t.Fatalf("state [%s] after failed probe, expect open", s)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if err = send(false); err != nil {
+		t.Fatalf("half-open probe error [%s]", err)
+	}
+	if s := b.state(strHost); s != CIRCUIT_STATE_CLOSED {
+		t.Fatalf("state [%s] after succeeded probe, expect closed", s)
+	}
+	var expect = []CircuitState{CIRCUIT_STATE_OPEN, CIRCUIT_STATE_HALF_OPEN, CIRCUIT_STATE_OPEN, CIRCUIT_STATE_HALF_OPEN, CIRCUIT_STATE_CLOSED}
+	if len(changes) != len(expect) {
+		t.Fatalf("state changes %v, expect %v", changes, expect)
+	}
+	for i := range expect {
+		if changes[i] != expect[i] {
+			t.Fatalf("state changes %v, expect %v", changes, expect)
+		}
+	}
+}
+
+func TestCircuitBreakerMinRequests(t *testing.T) {
+	const strHost = "api.example.com:443"
+	b := newCircuitBreaker(&CircuitBreakerPolicy{MinRequests: 3})
+	for i := 0; i < 2; i++ {
+		generation, err := b.allow(strHost)
+		if err != nil {
+			t.Fatalf("request [%d] error [%s]", i, err)
+		}
+		b.done(strHost, generation, true)
+	}
+	if s := b.state(strHost); s != CIRCUIT_STATE_CLOSED {
+		t.Fatalf("state [%s] before min requests, expect closed", s)
+	}
+}
+
+func TestCircuitBreakerStaleGeneration(t *testing.T) {
+	const strHost = "api.example.com:443"
+	b := newCircuitBreaker(&CircuitBreakerPolicy{MinRequests: 1, CoolDown: time.Hour})
+	stale, _ := b.allow(strHost)
+	generation, _ := b.allow(strHost)
+	b.done(strHost, generation, true)
+	if s := b.state(strHost); s != CIRCUIT_STATE_OPEN {
+		t.Fatalf("state [%s] after failure, expect open", s)
+	}
+	b.done(strHost, stale, false) //the result of request sent before the circuit opened is ignored
+	if s := b.state(strHost); s != CIRCUIT_STATE_OPEN {
+		t.Fatalf("state [%s] after stale result, expect open", s)
+	}
+}
+
+func TestCircuitBreakerSweep(t *testing.T) {
+	b := newCircuitBreaker(&CircuitBreakerPolicy{Window: time.Minute, MinRequests: 1, CoolDown: time.Hour})
+	idle, _ := b.allow("idle.example.com:80")
+	b.done("idle.example.com:80", idle, false)
+	_, _ = b.allow("inflight.example.com:80")
+	open, _ := b.allow("open.example.com:80")
+	b.done("open.example.com:80", open, true)
+
+	b.sweep(time.Now().Add(2 * time.Minute))
+	if _, ok := b.circuit["idle.example.com:80"]; ok {
+		t.Errorf("idle closed circuit is not removed")
+	}
+	if _, ok := b.circuit["inflight.example.com:80"]; !ok {
+		t.Errorf("circuit with request in flight is removed")
+	}
+	if _, ok := b.circuit["open.example.com:80"]; !ok {
+		t.Errorf("open circuit is removed")
+	}
+
+	stale, _ := b.allow("inflight.example.com:80")
+	delete(b.circuit, "inflight.example.com:80")
+	generation, _ := b.allow("inflight.example.com:80")
+	if generation == stale {
+		t.Errorf("recreated circuit reused generation [%d]", generation)
+	}
+}
@@ -0,0 +1,144 @@
+package httpc
+
+import (
+	"crypto/tls"
+	"github.com/civet148/log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_CERT_CHECK_INTERVAL = 10 * time.Second
+)
+
+// CertProvider returns the client certificate for a new TLS handshake
+type CertProvider func() (*tls.Certificate, error)
+
+// CertReloader serve client certificate from cert/key files and reload them when the files are rotated.
+// the files are checked at most once per interval on TLS handshake, so there is no background routine
+// and the established connections are kept
+type CertReloader struct {
+	certFile  string
+	keyFile   string
+	interval  time.Duration
+	locker    sync.Mutex
+	cert      *tls.Certificate
+	certStat  fileStamp
+	keyStat   fileStamp
+	lastCheck time.Time
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewCertReloader load client certificate from cert/key files and check changes of them every interval (default 10s)
+func NewCertReloader(strCertFile, strKeyFile string, interval time.Duration) (r *CertReloader, err error) {
+	if interval <= 0 {
+		interval = DEFAULT_CERT_CHECK_INTERVAL
+	}
+	r = &CertReloader{
+		certFile: strCertFile,
+		keyFile:  strKeyFile,
+		interval: interval,
+	}
+	if err = r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload load client certificate from cert/key files immediately
+func (r *CertReloader) Reload() error {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	return r.reload()
+}
+
+// Certificate returns the current client certificate, the files will be reloaded if they have been changed
+func (r *CertReloader) Certificate() (*tls.Certificate, error) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	if time.Since(r.lastCheck) >= r.interval {
+		r.lastCheck = time.Now()
+		if r.changed() {
+			if err := r.reload(); err != nil {
+				//the files may be in the middle of rotation, keep using the old certificate
+				log.Warnf("reload client certificate error [%s], keep the old one", err)
+			}
+		}
+	}
+	return r.cert, nil
+}
+
+// GetClientCertificate could be used as tls.Config.GetClientCertificate
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.Certificate()
+}
+
+func (r *CertReloader) reload() error {
+	certStat, err := statFile(r.certFile)
+	if err != nil {
+		return log.Errorf("stat certificate file [%s] error [%s]", r.certFile, err)
+	}
+	keyStat, err := statFile(r.keyFile)
+	if err != nil {
+		return log.Errorf("stat key file [%s] error [%s]", r.keyFile, err)
+	}
+	cert, err := LoadClientCert(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.certStat = certStat
+	r.keyStat = keyStat
+	r.lastCheck = time.Now()
+	log.Debugf("client certificate [%s] key [%s] loaded", r.certFile, r.keyFile)
+	return nil
+}
+
+func (r *CertReloader) changed() bool {
+	certStat, err := statFile(r.certFile)
+	if err != nil {
+		return false
+	}
+	keyStat, err := statFile(r.keyFile)
+	if err != nil {
+		return false
+	}
+	return !certStat.equal(r.certStat) || !keyStat.equal(r.keyStat)
+}
+
+func (s fileStamp) equal(other fileStamp) bool {
+	return s.size == other.size && s.modTime.Equal(other.modTime)
+}
+
+func statFile(strPath string) (stamp fileStamp, err error) {
+	var fi os.FileInfo
+	if fi, err = os.Stat(strPath); err != nil {
+		return stamp, err
+	}
+	return fileStamp{modTime: fi.ModTime(), size: fi.Size()}, nil
+}
+
+// withCertProvider clone the TLS config and serve client certificate by provider
+func withCertProvider(conf *tls.Config, provider CertProvider) *tls.Config {
+	if conf != nil {
+		conf = conf.Clone()
+	} else {
+		conf = &tls.Config{}
+	}
+	conf.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		cert, err := provider()
+		if err != nil {
+			return nil, log.Errorf("get client certificate error [%s]", err)
+		}
+		if cert == nil { //send no certificate
+			return &tls.Certificate{}, nil
+		}
+		return cert, nil
+	}
+	return conf
+}
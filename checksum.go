@@ -0,0 +1,255 @@
+package httpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/civet148/log"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	HEADER_KEY_CONTENT_MD5 = "Content-MD5"
+	HEADER_KEY_DIGEST      = "Digest"      //instance digest of RFC 3230, e.g. SHA-256=base64
+	HEADER_KEY_REPR_DIGEST = "Repr-Digest" //representation digest of RFC 9530, e.g. sha-256=:base64:
+)
+
+const (
+	CHECKSUM_MD5    = "md5"
+	CHECKSUM_SHA256 = "sha256"
+	CHECKSUM_SHA512 = "sha512"
+)
+
+const (
+	CHECKSUM_SOURCE_EXPECTED = "expected" //the checksum passed to SaveFileVerify/CopyFileVerify
+)
+
+// ChecksumError the checksum of downloaded content mismatched, the partial file of SaveFile has been removed
+type ChecksumError struct {
+	Algorithm string //checksum algorithm, md5/sha256/sha512
+	Source    string //source of expected checksum, "expected" or the response header key
+	Expected  string //expected checksum in hex
+	Actual    string //actual checksum in hex
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch, expected [%s] from [%s] actual [%s]", e.Algorithm, e.Expected, e.Source, e.Actual)
+}
+
+type checksumContextKey struct{}
+
+type checksumExpect struct {
+	algorithm string
+	source    string
+	sum       []byte
+}
+
+// digestVerifier compute the checksums of content and compare them with the expected ones
+type digestVerifier struct {
+	expects []*checksumExpect
+	hashes  map[string]hash.Hash
+}
+
+// withChecksumContext validate the expected checksum and returns a context carrying it to the verifier of download,
+// strAlgorithm is md5/sha256/sha512 (or sha-256 etc.) and strExpected is encoded in hex or base64
+func withChecksumContext(ctx context.Context, strAlgorithm, strExpected string) (context.Context, error) {
+	algorithm := strings.ToLower(strings.Replace(strAlgorithm, "-", "", -1))
+	if newHash(algorithm) == nil {
+		return nil, log.Errorf("unsupported checksum algorithm [%s]", strAlgorithm)
+	}
+	sum, ok := decodeChecksum(algorithm, strExpected)
+	if !ok {
+		return nil, log.Errorf("invalid %s checksum [%s]", algorithm, strExpected)
+	}
+	return context.WithValue(ctx, checksumContextKey{}, &checksumExpect{algorithm: algorithm, source: CHECKSUM_SOURCE_EXPECTED, sum: sum}), nil
+}
+
+// send a http request by GET method and save to file, the content must match the expected checksum, see SaveFileVerifyContext
+func (c *Client) SaveFileVerify(strUrl, strFilePath, strAlgorithm, strExpected string, queries ...url.Values) (size int64, err error) {
+	return c.SaveFileVerifyContext(context.Background(), strUrl, strFilePath, strAlgorithm, strExpected, queries...)
+}
+
+// send a http request by GET method with context and save to file like SaveFileContext, the content must match the
+// expected checksum. strAlgorithm is md5/sha256/sha512 and strExpected is encoded in hex or base64, they are validated
+// before sending request. *ChecksumError returned and the partial file removed if mismatched
+func (c *Client) SaveFileVerifyContext(ctx context.Context, strUrl, strFilePath, strAlgorithm, strExpected string, queries ...url.Values) (size int64, err error) {
+	if ctx, err = withChecksumContext(ctx, strAlgorithm, strExpected); err != nil {
+		return 0, err
+	}
+	return c.saveFile(ctx, strUrl, strFilePath, queries...)
+}
+
+// send a http request by GET method and save to file by concurrent range requests, the content must match the expected
+// checksum, see SaveFileVerifyContext and SaveFileParallelContext
+func (c *Client) SaveFileParallelVerify(strUrl, strFilePath string, segments int, strAlgorithm, strExpected string, queries ...url.Values) (size int64, err error) {
+	return c.SaveFileParallelVerifyContext(context.Background(), strUrl, strFilePath, segments, strAlgorithm, strExpected, queries...)
+}
+
+// send a http request by GET method with context and save to file by concurrent range requests, the content must match
+// the expected checksum, see SaveFileVerifyContext and SaveFileParallelContext
+func (c *Client) SaveFileParallelVerifyContext(ctx context.Context, strUrl, strFilePath string, segments int, strAlgorithm, strExpected string, queries ...url.Values) (size int64, err error) {
+	if ctx, err = withChecksumContext(ctx, strAlgorithm, strExpected); err != nil {
+		return 0, err
+	}
+	return c.SaveFileParallelContext(ctx, strUrl, strFilePath, segments, queries...)
+}
+
+// send a http request by GET method and copy to writer, the content must match the expected checksum, see CopyFileVerifyContext
+func (c *Client) CopyFileVerify(strUrl string, writer io.Writer, strAlgorithm, strExpected string, queries ...url.Values) (written int64, err error) {
+	return c.CopyFileVerifyContext(context.Background(), strUrl, writer, strAlgorithm, strExpected, queries...)
+}
+
+// send a http request by GET method with context and copy to writer, the content must match the expected checksum which
+// is validated before sending request. *ChecksumError returned if mismatched, the content has been written to writer
+func (c *Client) CopyFileVerifyContext(ctx context.Context, strUrl string, writer io.Writer, strAlgorithm, strExpected string, queries ...url.Values) (written int64, err error) {
+	if ctx, err = withChecksumContext(ctx, strAlgorithm, strExpected); err != nil {
+		return 0, err
+	}
+	return c.CopyFileContext(ctx, strUrl, writer, queries...)
+}
+
+// WithVerifyDigest verify the content of SaveFile/CopyFile by Content-MD5, Digest or Repr-Digest response headers if present
+func (c *Client) WithVerifyDigest(verify bool) *Client {
+	c.locker.Lock()
+	c.verifyDigest = verify
+	c.locker.Unlock()
+	return c
+}
+
+// newVerifier make a verifier by the expected checksum of context and the digests of response header,
+// Content-MD5 is ignored if the response is partial content and the header digests are ignored if the body
+// was decoded since they are computed over the encoded bytes. nil returned if there is nothing to verify
+func (c *Client) newVerifier(ctx context.Context, header http.Header, partial, decoded bool) (v *digestVerifier) {
+	v = &digestVerifier{hashes: make(map[string]hash.Hash)}
+	if expect, ok := ctx.Value(checksumContextKey{}).(*checksumExpect); ok {
+		v.add(expect.algorithm, expect.source, expect.sum)
+	}
+	c.locker.RLock()
+	verifyDigest := c.verifyDigest
+	c.locker.RUnlock()
+	if verifyDigest && decoded {
+		log.Debugf("response body was decoded, skip verifying digest headers")
+	}
+	if verifyDigest && header != nil && !decoded {
+		if strValue := header.Get(HEADER_KEY_CONTENT_MD5); strValue != "" && !partial {
+			if sum, ok := decodeChecksum(CHECKSUM_MD5, strValue); ok {
+				v.add(CHECKSUM_MD5, HEADER_KEY_CONTENT_MD5, sum)
+			}
+		}
+		for _, strKey := range []string{HEADER_KEY_DIGEST, HEADER_KEY_REPR_DIGEST} {
+			for _, strValue := range header.Values(strKey) {
+				v.addDigests(strKey, strValue)
+			}
+		}
+	}
+	if len(v.expects) == 0 {
+		return nil
+	}
+	return v
+}
+
+// reader compute the checksums of content read from r
+func (v *digestVerifier) reader(r io.Reader) io.Reader {
+	if v == nil {
+		return r
+	}
+	var writers []io.Writer
+	for _, h := range v.hashes {
+		writers = append(writers, h)
+	}
+	return io.TeeReader(r, io.MultiWriter(writers...))
+}
+
+// feedFile compute the checksums of the content already saved in file
+func (v *digestVerifier) feedFile(strPath string) error {
+	if v == nil {
+		return nil
+	}
+	f, err := os.Open(strPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(ioutil.Discard, v.reader(f))
+	return err
+}
+
+// verify compare the checksums of content with the expected ones
+func (v *digestVerifier) verify() error {
+	if v == nil {
+		return nil
+	}
+	for _, expect := range v.expects {
+		actual := v.hashes[expect.algorithm].Sum(nil)
+		if !bytes.Equal(actual, expect.sum) {
+			return &ChecksumError{
+				Algorithm: expect.algorithm,
+				Source:    expect.source,
+				Expected:  hex.EncodeToString(expect.sum),
+				Actual:    hex.EncodeToString(actual),
+			}
+		}
+	}
+	return nil
+}
+
+func (v *digestVerifier) add(strAlgorithm, strSource string, sum []byte) {
+	if _, ok := v.hashes[strAlgorithm]; !ok {
+		v.hashes[strAlgorithm] = newHash(strAlgorithm)
+	}
+	v.expects = append(v.expects, &checksumExpect{algorithm: strAlgorithm, source: strSource, sum: sum})
+}
+
+// addDigests parse digest header value like "SHA-256=base64,MD5=base64" or "sha-256=:base64:", unknown algorithms are ignored
+func (v *digestVerifier) addDigests(strKey, strValue string) {
+	for _, item := range strings.Split(strValue, ",") {
+		idx := strings.Index(item, "=")
+		if idx < 0 {
+			continue
+		}
+		strAlgorithm := strings.ToLower(strings.Replace(strings.TrimSpace(item[:idx]), "-", "", -1))
+		if newHash(strAlgorithm) == nil {
+			continue
+		}
+		strSum := strings.Trim(strings.TrimSpace(item[idx+1:]), ":")
+		if sum, err := base64.StdEncoding.DecodeString(strSum); err == nil && len(sum) == newHash(strAlgorithm).Size() {
+			v.add(strAlgorithm, strKey, sum)
+		}
+	}
+}
+
+func newHash(strAlgorithm string) hash.Hash {
+	switch strAlgorithm {
+	case CHECKSUM_MD5:
+		return md5.New()
+	case CHECKSUM_SHA256:
+		return sha256.New()
+	case CHECKSUM_SHA512:
+		return sha512.New()
+	}
+	return nil
+}
+
+// decodeChecksum decode checksum in hex or base64
+func decodeChecksum(strAlgorithm, strValue string) ([]byte, bool) {
+	size := newHash(strAlgorithm).Size()
+	strValue = strings.TrimSpace(strValue)
+	if sum, err := hex.DecodeString(strValue); err == nil && len(sum) == size {
+		return sum, true
+	}
+	if sum, err := base64.StdEncoding.DecodeString(strValue); err == nil && len(sum) == size {
+		return sum, true
+	}
+	return nil, false
+}
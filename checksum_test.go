@@ -0,0 +1,217 @@
+package httpc
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddDigests(t *testing.T) {
+	sha := sha256.Sum256([]byte("hello"))
+	sum := md5.Sum([]byte("hello"))
+	strSha, strMd5 := base64.StdEncoding.EncodeToString(sha[:]), base64.StdEncoding.EncodeToString(sum[:])
+	var cases = []struct {
+		value  string
+		expect []string //algorithms added in order
+	}{
+		{"SHA-256=" + strSha, []string{CHECKSUM_SHA256}},
+		{"sha-256=:" + strSha + ":", []string{CHECKSUM_SHA256}},
+		{"SHA-256=" + strSha + ", MD5=" + strMd5, []string{CHECKSUM_SHA256, CHECKSUM_MD5}},
+		{"sha-1=:AAAA:, sha-256=:" + strSha + ":", []string{CHECKSUM_SHA256}}, //unknown algorithm ignored
+		{"SHA-256=" + strMd5, nil},                                            //wrong size ignored
+		{"SHA-256=not base64!", nil},                                          //malformed ignored
+		{"SHA-256", nil},                                                      //no value
+		{"", nil},
+	}
+	for _, c := range cases {
+		v := &digestVerifier{hashes: make(map[string]hash.Hash)}
+		v.addDigests(HEADER_KEY_DIGEST, c.value)
+		var got []string
+		for _, expect := range v.expects {
+			got = append(got, expect.algorithm)
+			if expect.source != HEADER_KEY_DIGEST {
+				t.Errorf("addDigests(%q) source [%s], expect [%s]", c.value, expect.source, HEADER_KEY_DIGEST)
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(c.expect, ",") {
+			t.Errorf("addDigests(%q) added %v, expect %v", c.value, got, c.expect)
+		}
+	}
+}
+
+func TestDecodeChecksum(t *testing.T) {
+	sha := sha256.Sum256([]byte("hello"))
+	var cases = []struct {
+		value string
+		ok    bool
+	}{
+		{hex.EncodeToString(sha[:]), true},
+		{strings.ToUpper(hex.EncodeToString(sha[:])), true},
+		{" " + base64.StdEncoding.EncodeToString(sha[:]) + " ", true},
+		{hex.EncodeToString(sha[:16]), false},
+		{"zz", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		sum, ok := decodeChecksum(CHECKSUM_SHA256, c.value)
+		if ok != c.ok || (ok && !bytes.Equal(sum, sha[:])) {
+			t.Errorf("decodeChecksum(%q) = (%x, %v), expect ok [%v]", c.value, sum, ok, c.ok)
+		}
+	}
+}
+
+// digestServer serve content with the headers, the number of requests is counted
+type digestServer struct {
+	content  []byte
+	header   http.Header
+	requests int
+}
+
+func (s *digestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests++
+	for k, vs := range s.header {
+		w.Header()[k] = vs
+	}
+	_, _ = w.Write(s.content)
+}
+
+func TestSaveFileVerify(t *testing.T) {
+	content := []byte(strings.Repeat("checksum", 1000))
+	sha := sha256.Sum256(content)
+	sum := md5.Sum(content)
+	var cases = []struct {
+		name      string
+		algorithm string
+		expected  string
+		sent      bool //request sent
+		mismatch  bool
+	}{
+		{"sha256 hex", CHECKSUM_SHA256, hex.EncodeToString(sha[:]), true, false},
+		{"sha-256 base64", "SHA-256", base64.StdEncoding.EncodeToString(sha[:]), true, false},
+		{"md5", CHECKSUM_MD5, hex.EncodeToString(sum[:]), true, false},
+		{"mismatch", CHECKSUM_SHA256, hex.EncodeToString(make([]byte, sha256.Size)), true, true},
+		{"bad algorithm", "sha1", hex.EncodeToString(sha[:20]), false, false},
+		{"bad checksum", CHECKSUM_SHA256, hex.EncodeToString(sum[:]), false, false},
+	}
+	for _, c := range cases {
+		srv := &digestServer{content: content}
+		ts := httptest.NewServer(srv)
+		strDir, err := ioutil.TempDir("", "httpc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		strFile := filepath.Join(strDir, "data.bin")
+
+		_, err = NewClient().SaveFileVerify(ts.URL, strFile, c.algorithm, c.expected)
+		var checksumErr *ChecksumError
+		switch {
+		case !c.sent:
+			if err == nil || srv.requests != 0 {
+				t.Errorf("%s: error [%v] requests [%d], expect error before sending request", c.name, err, srv.requests)
+			}
+		case c.mismatch:
+			if !errors.As(err, &checksumErr) || checksumErr.Source != CHECKSUM_SOURCE_EXPECTED || checksumErr.Actual != hex.EncodeToString(sha[:]) {
+				t.Errorf("%s: error [%v], expect *ChecksumError of expected checksum", c.name, err)
+			}
+		default:
+			if err != nil {
+				t.Errorf("%s: error [%s]", c.name, err)
+			}
+		}
+		_, e := os.Stat(strFile)
+		if exists := e == nil; exists != (c.sent && !c.mismatch) {
+			t.Errorf("%s: file exists [%v]", c.name, exists)
+		}
+		if _, e = os.Stat(strFile + DOWNLOAD_PART_SUFFIX); e == nil {
+			t.Errorf("%s: partial file is not removed", c.name)
+		}
+		ts.Close()
+		_ = os.RemoveAll(strDir)
+	}
+}
+
+func TestSaveFileVerifyResumeMismatch(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 1000))
+	srv := &rangeServer{etag: `"v1"`, content: content}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	strDir, err := ioutil.TempDir("", "httpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(strDir)
+	strFile := filepath.Join(strDir, "data.bin")
+	partial := bytes.Repeat([]byte("x"), 4000) //corrupted partial file with the same validator
+	if err = ioutil.WriteFile(strFile+DOWNLOAD_PART_SUFFIX, partial, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = savePartMeta(strFile+DOWNLOAD_META_SUFFIX, &partMeta{Url: ts.URL, ETag: `"v1"`}); err != nil {
+		t.Fatal(err)
+	}
+
+	sha := sha256.Sum256(content)
+	_, err = NewClient().SaveFileVerify(ts.URL, strFile, CHECKSUM_SHA256, hex.EncodeToString(sha[:]))
+	var checksumErr *ChecksumError
+	if !errors.As(err, &checksumErr) {
+		t.Fatalf("resume corrupted partial file error [%v], expect *ChecksumError", err)
+	}
+	if len(srv.ranges) != 1 || srv.ranges[0] != "bytes=4000-" {
+		t.Errorf("range headers %q, expect resumed from 4000", srv.ranges)
+	}
+	for _, strPath := range []string{strFile, strFile + DOWNLOAD_PART_SUFFIX, strFile + DOWNLOAD_META_SUFFIX} {
+		if _, e := os.Stat(strPath); e == nil {
+			t.Errorf("file [%s] exists after checksum mismatch", strPath)
+		}
+	}
+	if _, err = NewClient().SaveFileVerify(ts.URL, strFile, CHECKSUM_SHA256, hex.EncodeToString(sha[:])); err != nil {
+		t.Errorf("download again error [%s]", err)
+	}
+}
+
+func TestVerifyDigestHeader(t *testing.T) {
+	content := []byte(strings.Repeat("digest", 1000))
+	sha := sha256.Sum256(content)
+	sum := md5.Sum(content)
+	wrong := sha256.Sum256([]byte("wrong"))
+	wrongMd5 := md5.Sum([]byte("wrong"))
+	strSha, strWrong := base64.StdEncoding.EncodeToString(sha[:]), base64.StdEncoding.EncodeToString(wrong[:])
+	var cases = []struct {
+		name   string
+		header http.Header
+		verify bool
+		source string //source of mismatched checksum, empty means no error
+	}{
+		{"digest", http.Header{HEADER_KEY_DIGEST: {"SHA-256=" + strSha}}, true, ""},
+		{"repr digest", http.Header{HEADER_KEY_REPR_DIGEST: {"sha-256=:" + strSha + ":"}}, true, ""},
+		{"content md5", http.Header{HEADER_KEY_CONTENT_MD5: {base64.StdEncoding.EncodeToString(sum[:])}}, true, ""},
+		{"digest mismatch", http.Header{HEADER_KEY_DIGEST: {"SHA-256=" + strWrong}}, true, HEADER_KEY_DIGEST},
+		{"repr digest mismatch", http.Header{HEADER_KEY_REPR_DIGEST: {"sha-256=:" + strWrong + ":"}}, true, HEADER_KEY_REPR_DIGEST},
+		{"content md5 mismatch", http.Header{HEADER_KEY_CONTENT_MD5: {base64.StdEncoding.EncodeToString(wrongMd5[:])}}, true, HEADER_KEY_CONTENT_MD5},
+		{"content md5 wrong size", http.Header{HEADER_KEY_CONTENT_MD5: {strWrong}}, true, ""},
+		{"not verified", http.Header{HEADER_KEY_DIGEST: {"SHA-256=" + strWrong}}, false, ""},
+	}
+	for _, c := range cases {
+		ts := httptest.NewServer(&digestServer{content: content, header: c.header})
+		var buf bytes.Buffer
+		_, err := NewClient().WithVerifyDigest(c.verify).CopyFile(ts.URL, &buf)
+		var checksumErr *ChecksumError
+		if c.source == "" && err != nil {
+			t.Errorf("%s: error [%s]", c.name, err)
+		}
+		if c.source != "" && (!errors.As(err, &checksumErr) || checksumErr.Source != c.source) {
+			t.Errorf("%s: error [%v], expect *ChecksumError from [%s]", c.name, err, c.source)
+		}
+		ts.Close()
+	}
+}
@@ -2,9 +2,9 @@ package httpc
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
-	"fmt"
 	"github.com/civet148/log"
 	"io"
 	"io/ioutil"
@@ -15,7 +15,6 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
-	"time"
 )
 
 type uploadFile struct {
@@ -24,9 +23,24 @@ type uploadFile struct {
 }
 
 type Client struct {
-	cli    http.Client
-	header http.Header
-	locker sync.RWMutex
+	cli          http.Client
+	header       http.Header
+	locker       sync.RWMutex
+	retry        *RetryPolicy
+	middlewares  []Middleware
+	strictStatus bool
+	baseUrl      string
+	pathParams   map[string]string
+	sortQuery    bool
+
+	acceptEncodings  []string
+	compressEncoding string
+	compressMinSize  int
+	limiter          *rateLimiter
+	breaker          *circuitBreaker
+	progress         ProgressFunc
+	verifyDigest     bool
+	preserveModTime  bool
 }
 
 func init() {
@@ -34,15 +48,35 @@ func init() {
 }
 
 func NewClient(opts ...*Option) (c *Client) {
-	var header http.Header
-	var tlsConf *tls.Config
+	var header = http.Header{}
+	var retry *RetryPolicy
+	var strictStatus bool
+	var strBaseUrl string
+	var pathParams map[string]string
+	var sortQuery bool
+	var acceptEncodings []string
+	var compressEncoding string
+	var compressMinSize = DEFAULT_COMPRESS_MIN_SIZE
+	var progress ProgressFunc
 	var opt *Option
 	for _, o := range opts {
 		opt = o
 	}
 	if opt != nil {
-		header = opt.Header
-		tlsConf = opt.TlsConf
+		if opt.Header != nil {
+			header = opt.Header.Clone()
+		}
+		retry = opt.Retry.normalize()
+		strictStatus = opt.StrictStatus
+		strBaseUrl = opt.BaseUrl
+		pathParams = opt.PathParams
+		sortQuery = opt.SortQuery
+		acceptEncodings = opt.AcceptEncoding
+		compressEncoding = opt.CompressRequest
+		if opt.CompressMinSize > 0 {
+			compressMinSize = opt.CompressMinSize
+		}
+		progress = opt.Progress
 	} else {
 		opt = &Option{
 			Timeout: 30,
@@ -50,18 +84,28 @@ func NewClient(opts ...*Option) (c *Client) {
 			TlsConf: &tls.Config{},
 		}
 	}
-	var transport http.RoundTripper
-	if tlsConf != nil {
-		transport = &http.Transport{
-			TLSClientConfig: tlsConf,
-		}
-	}
+	transport := newTransport(opt)
 	log.Debugf("TLS transport [%+v]", transport)
 	return &Client{
-		header: header,
+		header:       header,
+		retry:        retry,
+		strictStatus: strictStatus,
+		baseUrl:      strBaseUrl,
+		pathParams:   pathParams,
+		sortQuery:    sortQuery,
+
+		acceptEncodings:  acceptEncodings,
+		compressEncoding: compressEncoding,
+		compressMinSize:  compressMinSize,
+		limiter:          newRateLimiter(opt.RateLimit),
+		breaker:          newCircuitBreaker(opt.CircuitBreaker),
+		progress:         progress,
+		verifyDigest:     opt.VerifyDigest,
+		preserveModTime:  opt.PreserveModTime,
 		cli: http.Client{
 			Transport: transport,
-			Timeout:   time.Duration(opt.Timeout) * time.Second,
+			Timeout:   requestTimeout(opt),
+			Jar:       newClientCookieJar(opt),
 		},
 	}
 }
@@ -75,10 +119,9 @@ func (c *Client) Debug() *Client {
 	return c
 }
 
+// GetHeader returns the default headers map of client, it's not guarded by lock so modify it only when setting up
+// client before sending any request. use SetHeader to change the headers while requests are being sent
 func (c *Client) GetHeader() http.Header {
-	if c.header == nil {
-		c.header = http.Header{}
-	}
 	return c.header
 }
 
@@ -113,80 +156,98 @@ func (c *Client) WithBearerToken(token string) *Client {
 }
 
 func (c *Client) GetEx(strUrl string, values url.Values, v interface{}) (status int, err error) {
+	return c.GetExContext(context.Background(), strUrl, values, v)
+}
+
+// GetEx with context
+func (c *Client) GetExContext(ctx context.Context, strUrl string, values url.Values, v interface{}) (status int, err error) {
 	var r *Response
-	if r, err = c.Get(strUrl, values); err != nil {
-		return http.StatusBadGateway, err
+	if r, err = c.GetContext(ctx, strUrl, values); err != nil {
+		return statusOfError(err), err
 	}
-	if r.StatusCode == http.StatusOK {
-		if err = r.Unmarshal(v); err != nil {
-			log.Errorf("unmarshal response body data to struct error [%s]", err)
-			return r.StatusCode, err
-		}
+	if r.StatusCode != http.StatusOK {
+		return r.StatusCode, newStatusError(HTTP_METHOD_GET, strUrl, r)
+	}
+	if err = r.Unmarshal(v); err != nil {
+		log.Errorf("unmarshal response body data to struct error [%s]", err)
+		return r.StatusCode, err
 	}
 	return r.StatusCode, nil
 }
 
 // send a http request by GET method
 func (c *Client) Get(strUrl string, values url.Values) (r *Response, err error) {
-	return c.get(strUrl, values)
+	return c.GetContext(context.Background(), strUrl, values)
+}
+
+// send a http request by GET method with context
+func (c *Client) GetContext(ctx context.Context, strUrl string, values url.Values) (r *Response, err error) {
+	return c.get(ctx, strUrl, values)
 }
 
 // send a http request by GET method and copy to writter
 func (c *Client) CopyFile(strUrl string, writer io.Writer, queries ...url.Values) (written int64, err error) {
-	var r *http.Response
-	for _, query := range queries { //URL路径查询参数
-		strUrl = fmt.Sprintf("%s?%s", strUrl, query.Encode())
-	}
-	r, err = http.Get(strUrl)
-	if err != nil {
-		return 0, err
-	}
-	defer r.Body.Close()
-	written, err = io.Copy(writer, r.Body)
-	if err != nil {
-		return 0, err
-	}
-	return
+	return c.CopyFileContext(context.Background(), strUrl, writer, queries...)
 }
 
-// send a http request by GET method and save to file
-func (c *Client) SaveFile(strUrl string, strFilePath string, queries ...url.Values) (written int64, err error) {
-	var r *http.Response
-	for _, query := range queries { //URL路径查询参数
-		strUrl = fmt.Sprintf("%s?%s", strUrl, query.Encode())
-	}
-	r, err = http.Get(strUrl)
+// send a http request by GET method with context and copy to writter
+func (c *Client) CopyFileContext(ctx context.Context, strUrl string, writer io.Writer, queries ...url.Values) (written int64, err error) {
+	var r *Response
+	r, err = c.getStream(ctx, strUrl, queries...)
 	if err != nil {
 		return 0, err
 	}
-	defer r.Body.Close()
-	var dst *os.File
-	dst, err = os.Create(strFilePath)
+	defer r.stream.Close()
+	verifier := c.newVerifier(ctx, r.Header, false, r.decoded)
+	tracker := c.newProgress(ctx, strUrl, false, 0, contentLength(r.Header))
+	written, err = io.Copy(writer, verifier.reader(tracker.reader(r.stream, false)))
 	if err != nil {
 		return 0, err
 	}
-	defer dst.Close()
-	written, err = io.Copy(dst, r.Body)
-	if err != nil {
-		return 0, err
+	if err = verifier.verify(); err != nil {
+		return written, err
 	}
+	tracker.finish()
 	return
 }
 
+// send a http request by GET method and save to file
+func (c *Client) SaveFile(strUrl string, strFilePath string, queries ...url.Values) (size int64, err error) {
+	return c.SaveFileContext(context.Background(), strUrl, strFilePath, queries...)
+}
+
+// send a http request by GET method with context and save to file, returns the size of file.
+// the content is written to strFilePath.part and renamed to strFilePath after flushed to disk, non-2xx status is returned
+// as *HTTPError and strFilePath is untouched if download failed. the download is resumed by Range request if the
+// partial file of last interrupted download exists
+func (c *Client) SaveFileContext(ctx context.Context, strUrl string, strFilePath string, queries ...url.Values) (size int64, err error) {
+	return c.saveFile(ctx, strUrl, strFilePath, queries...)
+}
+
 // send a http request by POST method with application/x-www-form-urlencoded
 func (c *Client) PostUrlEncoded(strUrl string, values url.Values, queries ...url.Values) (r *Response, err error) {
-	return c.do(HTTP_METHOD_POST, strUrl, values, queries...)
+	return c.PostUrlEncodedContext(context.Background(), strUrl, values, queries...)
+}
+
+// send a http request by POST method with context and application/x-www-form-urlencoded
+func (c *Client) PostUrlEncodedContext(ctx context.Context, strUrl string, values url.Values, queries ...url.Values) (r *Response, err error) {
+	return c.do(ctx, "", HTTP_METHOD_POST, strUrl, values, queries...)
 }
 
 // send a http request by GET method and unmarshal json data to struct v
 func (c *Client) GetJson(strUrl string, values url.Values, v interface{}) (status int, err error) {
+	return c.GetJsonContext(context.Background(), strUrl, values, v)
+}
+
+// send a http request by GET method with context and unmarshal json data to struct v
+func (c *Client) GetJsonContext(ctx context.Context, strUrl string, values url.Values, v interface{}) (status int, err error) {
 	var r *Response
-	if r, err = c.get(strUrl, values); err != nil {
+	if r, err = c.get(ctx, strUrl, values); err != nil {
 		log.Errorf("GET url [%s] values [%+v] error [%s]", strUrl, values, err.Error())
-		return
+		return statusOfError(err), err
 	}
 	if r.StatusCode != http.StatusOK {
-		err = fmt.Errorf("GET url [%s] values [%+v] remote server status code [%v]", strUrl, values, r.StatusCode)
+		err = newStatusError(HTTP_METHOD_GET, strUrl, r)
 		log.Errorf(err.Error())
 		return r.StatusCode, err
 	}
@@ -200,50 +261,86 @@ func (c *Client) GetJson(strUrl string, values url.Values, v interface{}) (statu
 
 // send a http request by PUT method
 func (c *Client) Put(strUrl string, body io.Reader, queries ...url.Values) (r *Response, err error) {
-	return c.SendRequest(c.header, HTTP_METHOD_PUT, strUrl, body, queries...)
+	return c.PutContext(context.Background(), strUrl, body, queries...)
+}
+
+// send a http request by PUT method with context
+func (c *Client) PutContext(ctx context.Context, strUrl string, body io.Reader, queries ...url.Values) (r *Response, err error) {
+	return c.do(ctx, "", HTTP_METHOD_PUT, strUrl, body, queries...)
 }
 
 // send a http request by DELETE method
 func (c *Client) Delete(strUrl string, queries ...url.Values) (r *Response, err error) {
-	return c.do(HTTP_METHOD_DELETE, strUrl, nil, queries...)
+	return c.DeleteContext(context.Background(), strUrl, queries...)
+}
+
+// send a http request by DELETE method with context
+func (c *Client) DeleteContext(ctx context.Context, strUrl string, queries ...url.Values) (r *Response, err error) {
+	return c.do(ctx, "", HTTP_METHOD_DELETE, strUrl, nil, queries...)
 }
 
 // send a http request by TRACE method
 func (c *Client) Trace(strUrl string, queries ...url.Values) (r *Response, err error) {
-	return c.do(HTTP_METHOD_TRACE, strUrl, nil, queries...)
+	return c.TraceContext(context.Background(), strUrl, queries...)
+}
+
+// send a http request by TRACE method with context
+func (c *Client) TraceContext(ctx context.Context, strUrl string, queries ...url.Values) (r *Response, err error) {
+	return c.do(ctx, "", HTTP_METHOD_TRACE, strUrl, nil, queries...)
 }
 
 // send a http request by PATCH method
 func (c *Client) Patch(strUrl string, queries ...url.Values) (r *Response, err error) {
-	return c.do(HTTP_METHOD_PATCH, strUrl, nil, queries...)
+	return c.PatchContext(context.Background(), strUrl, queries...)
+}
+
+// send a http request by PATCH method with context
+func (c *Client) PatchContext(ctx context.Context, strUrl string, queries ...url.Values) (r *Response, err error) {
+	return c.do(ctx, "", HTTP_METHOD_PATCH, strUrl, nil, queries...)
 }
 
 // send a http request by POST method with content-type specified
 // data type could be string,[]byte,url.Values,struct and so on
 func (c *Client) Post(strContentType string, strUrl string, data interface{}, queries ...url.Values) (r *Response, err error) {
-	c.setContentType(strContentType)
-	return c.do(HTTP_METHOD_POST, strUrl, data, queries...)
+	return c.PostContext(context.Background(), strContentType, strUrl, data, queries...)
+}
+
+// send a http request by POST method with context and content-type specified
+func (c *Client) PostContext(ctx context.Context, strContentType string, strUrl string, data interface{}, queries ...url.Values) (r *Response, err error) {
+	return c.do(ctx, strContentType, HTTP_METHOD_POST, strUrl, data, queries...)
 }
 
 // send a http request by POST method with content-type application/json
 // data type could be string,[]byte,url.Values,struct and so on and
 func (c *Client) PostJson(strUrl string, data interface{}, queries ...url.Values) (r *Response, err error) {
-	c.setContentType(CONTENT_TYPE_NAME_APPLICATION_JSON)
-	return c.do(HTTP_METHOD_POST, strUrl, data, queries...)
+	return c.PostJsonContext(context.Background(), strUrl, data, queries...)
+}
+
+// send a http request by POST method with context and content-type application/json
+func (c *Client) PostJsonContext(ctx context.Context, strUrl string, data interface{}, queries ...url.Values) (r *Response, err error) {
+	return c.do(ctx, CONTENT_TYPE_NAME_APPLICATION_JSON, HTTP_METHOD_POST, strUrl, data, queries...)
 }
 
 // send a http request by POST method with content-type text/plain
 // data type must could be string,[]byte,url.Values,struct and so on
 func (c *Client) PostTextPlain(strUrl string, data interface{}, queries ...url.Values) (r *Response, err error) {
-	c.setContentType(CONTENT_TYPE_NAME_TEXT_PLAIN)
-	return c.do(HTTP_METHOD_POST, strUrl, data, queries...)
+	return c.PostTextPlainContext(context.Background(), strUrl, data, queries...)
+}
+
+// send a http request by POST method with context and content-type text/plain
+func (c *Client) PostTextPlainContext(ctx context.Context, strUrl string, data interface{}, queries ...url.Values) (r *Response, err error) {
+	return c.do(ctx, CONTENT_TYPE_NAME_TEXT_PLAIN, HTTP_METHOD_POST, strUrl, data, queries...)
 }
 
 // send a http request by POST method with content-type multipart/form-data
 // data type must could be string,[]byte,url.Values,struct and so on
 func (c *Client) PostFormData(strUrl string, data interface{}, queries ...url.Values) (r *Response, err error) {
-	c.setContentType(CONTENT_TYPE_NAME_MULTIPART_FORM_DATA)
-	return c.do(HTTP_METHOD_POST, strUrl, data, queries...)
+	return c.PostFormDataContext(context.Background(), strUrl, data, queries...)
+}
+
+// send a http request by POST method with context and content-type multipart/form-data
+func (c *Client) PostFormDataContext(ctx context.Context, strUrl string, data interface{}, queries ...url.Values) (r *Response, err error) {
+	return c.do(ctx, CONTENT_TYPE_NAME_MULTIPART_FORM_DATA, HTTP_METHOD_POST, strUrl, data, queries...)
 }
 
 /*
@@ -257,15 +354,23 @@ example:
 	}
 */
 func (c *Client) PostFormDataMultipart(strUrl string, params url.Values, queries ...url.Values) (r *Response, err error) {
-	c.setContentType(CONTENT_TYPE_NAME_MULTIPART_FORM_DATA)
-	return c.doPostFormDataMultipart(strUrl, params, queries...)
+	return c.PostFormDataMultipartContext(context.Background(), strUrl, params, queries...)
+}
+
+// send a http request by POST method with context and content-type multipart/form-data
+func (c *Client) PostFormDataMultipartContext(ctx context.Context, strUrl string, params url.Values, queries ...url.Values) (r *Response, err error) {
+	return c.doPostFormDataMultipart(ctx, strUrl, params, queries...)
 }
 
 // send a http request by POST method with content-type multipart/form-data
 // data type must could be string,[]byte,url.Values,struct and so on
 func (c *Client) PostFormUrlEncoded(strUrl string, data interface{}, queries ...url.Values) (r *Response, err error) {
-	c.setContentType(CONTENT_TYPE_NAME_X_WWW_FORM_URL_ENCODED)
-	return c.do(HTTP_METHOD_POST, strUrl, data, queries...)
+	return c.PostFormUrlEncodedContext(context.Background(), strUrl, data, queries...)
+}
+
+// send a http request by POST method with context and content-type application/x-www-form-urlencoded
+func (c *Client) PostFormUrlEncodedContext(ctx context.Context, strUrl string, data interface{}, queries ...url.Values) (r *Response, err error) {
+	return c.do(ctx, CONTENT_TYPE_NAME_X_WWW_FORM_URL_ENCODED, HTTP_METHOD_POST, strUrl, data, queries...)
 }
 
 func (c *Client) setHeader(key, value string) {
@@ -281,114 +386,136 @@ func (c *Client) setContentType(contentType string) {
 	c.setHeader(HEADER_KEY_CONTENT_TYPE, contentType)
 }
 
-// do send request to destination host
-func (c *Client) do(strMethod, strUrl string, data interface{}, queries ...url.Values) (r *Response, err error) {
-
-	var body io.Reader
+// do send request to destination host by a request builder, the shared client header will not be changed
+func (c *Client) do(ctx context.Context, strContentType, strMethod, strUrl string, data interface{}, queries ...url.Values) (r *Response, err error) {
+	req := c.R().SetContext(ctx).SetBody(data).SetQueryValues(queries...)
+	if strContentType != "" {
+		req.SetContentType(strContentType)
+	}
+	return req.Send(strMethod, strUrl)
+}
 
-	if data != nil {
+func (c *Client) get(ctx context.Context, strUrl string, values url.Values) (r *Response, err error) {
+	return c.R().SetContext(ctx).SetQueryValues(values).Get(strUrl)
+}
 
-		switch data.(type) { //请求体body
-		case url.Values:
-			{
-				values := data.(url.Values)
-				body = strings.NewReader(values.Encode())
-			}
-		case string:
-			{
-				body = strings.NewReader(data.(string))
-			}
-		case []byte:
-			{
-				body = bytes.NewReader(data.([]byte))
-			}
-		default:
-			{
-				var jsonData []byte
-				if jsonData, err = json.Marshal(data); err != nil {
-					log.Error("can't marshal data to json, error [%v]", err.Error())
-					return
-				}
-				body = bytes.NewReader(jsonData)
-			}
-		}
-	}
+// makeQueryUrl merge queries into url by client's query ordering option
+func (c *Client) makeQueryUrl(strUrl string, queries ...url.Values) string {
+	c.locker.RLock()
+	sorted := c.sortQuery
+	c.locker.RUnlock()
+	strUrl = MakeQueryUrl(strUrl, sorted, queries...)
+	log.Debugf("query url [%s]", strUrl)
+	return strUrl
+}
 
-	if r, err = c.SendRequest(c.header, strMethod, strUrl, body, queries...); err != nil {
-		return
-	}
-	return
+func (c *Client) SendRequest(header http.Header, strMethod, strUrl string, body io.Reader, queries ...url.Values) (r *Response, err error) {
+	return c.SendRequestContext(context.Background(), header, strMethod, strUrl, body, queries...)
 }
 
-func (c *Client) get(strUrl string, values url.Values) (r *Response, err error) {
+// SendRequestContext send request to destination host, the request will be canceled when ctx is done.
+// the header is sent as the complete set of request headers, client's headers are not merged, use R() to send with them
+func (c *Client) SendRequestContext(ctx context.Context, header http.Header, strMethod, strUrl string, body io.Reader, queries ...url.Values) (r *Response, err error) {
 
-	if values != nil {
-		u, err := url.Parse(strUrl)
-		if err != nil {
-			return nil, err
-		}
-		u.RawQuery = values.Encode()
-		strUrl = u.String()
+	strUrl = c.makeQueryUrl(c.resolveUrl(strUrl), queries...)
+	if header, body, err = c.compressRequest(header, body); err != nil {
+		return nil, err
 	}
-	return c.SendRequest(c.header, HTTP_METHOD_GET, strUrl, nil)
+	if r, err = c.sendRetry(ctx, header, strMethod, strUrl, body); err != nil {
+		return nil, err
+	}
+	c.locker.RLock()
+	strictStatus := c.strictStatus
+	c.locker.RUnlock()
+	if strictStatus && !isSuccessStatus(r.StatusCode) {
+		return nil, newStatusError(strMethod, strUrl, r)
+	}
+	return r, nil
 }
 
-func (c *Client) makeQueryUrl(strUrl string, queries ...url.Values) string {
-	if len(queries) == 0 {
-		return strUrl
+// sendRetry send request to destination host and retry by client's retry policy
+func (c *Client) sendRetry(ctx context.Context, header http.Header, strMethod, strUrl string, body io.Reader) (r *Response, err error) {
+	c.locker.RLock()
+	retry := c.retry
+	c.locker.RUnlock()
+	if _, ok := body.(*compressReader); ok || !retry.enabled(strMethod) { //never buffer a streaming compressed body
+		return c.sendOnce(ctx, header, strMethod, strUrl, body)
 	}
-	var params []string
-	for _, query := range queries { //URL路径查询参数
-		params = append(params, query.Encode())
+	//read body into memory so that it can be resent on retry
+	var data []byte
+	if body != nil {
+		if data, err = ioutil.ReadAll(body); err != nil {
+			return nil, log.Errorf("read request body error [%s]", err)
+		}
+	}
+	for attempt := 1; ; attempt++ {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(data)
+		}
+		r, err = c.sendOnce(ctx, header, strMethod, strUrl, reader)
+		if attempt >= retry.MaxAttempts || !retry.shouldRetry(ctx, r, err) {
+			return
+		}
+		if r != nil && r.stream != nil {
+			_ = r.stream.Close()
+		}
+		wait := retry.backoff(attempt, r)
+		log.Warnf("%s url [%s] attempt [%d/%d] failed, retry after [%v]", strMethod, strUrl, attempt, retry.MaxAttempts, wait)
+		if err = sleepContext(ctx, wait); err != nil {
+			return nil, err
+		}
 	}
-	query := strings.Join(params, "&")
-	strUrl = fmt.Sprintf("%s?%s", strUrl, query)
-	log.Debugf("query url [%s]", strUrl)
-	return strUrl
 }
 
-func (c *Client) SendRequest(header http.Header, strMethod, strUrl string, body io.Reader, queries ...url.Values) (r *Response, err error) {
+// sendOnce send request to destination host once through the middleware chain
+func (c *Client) sendOnce(ctx context.Context, header http.Header, strMethod, strUrl string, body io.Reader) (r *Response, err error) {
 
 	var req *http.Request
-	var resp *http.Response
-	strUrl = c.makeQueryUrl(strUrl, queries...)
-	if req, err = http.NewRequest(strMethod, strUrl, body); err != nil {
+	if req, err = http.NewRequestWithContext(ctx, strMethod, strUrl, body); err != nil {
 		log.Errorf("new request error [%s]", err)
 		return
 	}
 
 	if header != nil {
-		req.Header = header
+		req.Header = header.Clone()
 	}
-
-	if resp, err = c.cli.Do(req); err != nil {
-		log.Errorf("send request error [%s]", err)
-		return
+	if req.Body != nil && req.Body != http.NoBody {
+		var total int64 = -1
+		if req.ContentLength > 0 {
+			total = req.ContentLength
+		}
+		if tracker := c.newProgress(ctx, strUrl, true, 0, total); tracker != nil {
+			req.Body = &progressReadCloser{Reader: tracker.reader(req.Body, true), closer: req.Body}
+		}
 	}
-
-	defer resp.Body.Close()
-
-	r = &Response{
-		StatusCode:  resp.StatusCode,
-		ContentType: resp.Header.Get(HEADER_KEY_CONTENT_TYPE),
+	if strEncoding := c.acceptEncoding(); strEncoding != "" && req.Header.Get(HEADER_KEY_ACCEPT_ENCODING) == "" {
+		req.Header.Set(HEADER_KEY_ACCEPT_ENCODING, strEncoding)
 	}
-
-	if r.Body, err = ioutil.ReadAll(resp.Body); err != nil {
-		log.Errorf("%s", err)
-		return
+	if isUnixHost(req.URL.Host) {
+		req.Host = UNIX_HOST_HEADER
 	}
-	return
+	return c.sendCircuit(ctx, req.URL.Host, func() (*Response, error) {
+		if err = c.waitRateLimit(ctx, req.URL.Host); err != nil {
+			return nil, err
+		}
+		return c.execute(req)
+	})
 }
 
-func (c *Client) doPostFormDataMultipart(strUrl string, params url.Values, queries ...url.Values) (r *Response, err error) {
+// getStream send a GET request with client's headers asking for the raw bytes, the caller must close the response stream
+func (c *Client) getStream(ctx context.Context, strUrl string, queries ...url.Values) (r *Response, err error) {
+	return c.SendRequestContext(withStreamContext(ctx), c.downloadHeader(), HTTP_METHOD_GET, strUrl, nil, queries...)
+}
+
+func (c *Client) doPostFormDataMultipart(ctx context.Context, strUrl string, params url.Values, queries ...url.Values) (r *Response, err error) {
 	var body io.Reader
 	var contentType string
 	body, contentType, err = c.getMultipartReader(params)
 	if err != nil {
 		return nil, log.Errorf(err.Error())
 	}
-	c.setHeader(HEADER_KEY_CONTENT_TYPE, contentType)
-	return c.SendRequest(c.header, HTTP_METHOD_POST, strUrl, body, queries...)
+	return c.do(ctx, contentType, HTTP_METHOD_POST, strUrl, body, queries...)
 }
 
 func (c *Client) getMultipartReader(params url.Values) (reader io.Reader, contentType string, err error) {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
 	"github.com/civet148/httpc"
 	"github.com/civet148/httpc/mock"
@@ -12,6 +13,7 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"time"
 )
 
 const (
@@ -41,6 +43,11 @@ const (
 	CMD_FLAG_NAME_FORM     = "form"
 	CMD_FLAG_NAME_URL      = "url"
 	CMD_FLAG_NAME_OUTPUT   = "output"
+	CMD_FLAG_NAME_COOKIE   = "cookie-file"
+)
+
+const (
+	PROGRESS_BAR_WIDTH = 40
 )
 
 func init() {
@@ -78,9 +85,14 @@ func main() {
 		getCmd,
 	}
 	app := &cli.App{
-		Name:     ProgramName,
-		Version:  fmt.Sprintf("%s %s commit %s", Version, BuildTime, GitCommit),
-		Flags:    []cli.Flag{},
+		Name:    ProgramName,
+		Version: fmt.Sprintf("%s %s commit %s", Version, BuildTime, GitCommit),
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  CMD_FLAG_NAME_COOKIE,
+				Usage: "load cookies from and save cookies to file across invocations",
+			},
+		},
 		Commands: local,
 		Action:   nil,
 	}
@@ -136,7 +148,7 @@ var uploadCmd = &cli.Command{
 		},
 	},
 	Action: func(cctx *cli.Context) error {
-		c := httpc.NewClient()
+		c := newClient(cctx).WithProgress(printProgress)
 		form := cctx.String(CMD_FLAG_NAME_FORM)
 		if form == "" {
 			return log.Errorf("form-data key & value requires")
@@ -178,7 +190,7 @@ var downloadCmd = &cli.Command{
 		},
 	},
 	Action: func(cctx *cli.Context) error {
-		c := httpc.NewClient()
+		c := newClient(cctx).WithProgress(printProgress)
 		strOutput := cctx.String(CMD_FLAG_NAME_OUTPUT)
 		if strOutput == "" {
 			return log.Errorf("output file path requires")
@@ -202,7 +214,7 @@ var getCmd = &cli.Command{
 	Flags:     []cli.Flag{},
 	Action: func(cctx *cli.Context) error {
 		strUrl := cctx.Args().First()
-		c := httpc.NewClient()
+		c := newClient(cctx)
 		resp, err := c.Get(strUrl, nil)
 		if err != nil {
 			return log.Errorf("send request error [%s]", err)
@@ -212,6 +224,61 @@ var getCmd = &cli.Command{
 	},
 }
 
+func newClient(cctx *cli.Context) *httpc.Client {
+	return httpc.NewClient(&httpc.Option{
+		Timeout:    30,
+		TlsConf:    &tls.Config{},
+		CookieFile: cctx.String(CMD_FLAG_NAME_COOKIE),
+	})
+}
+
+// printProgress print progress bar of upload/download to stderr, like
+// [=========>                              ]  25.0%  2.5MiB/10.0MiB  1.2MiB/s  ETA 6s
+func printProgress(p *httpc.Progress) {
+	var strBar, strPercent, strSize string
+	if p.Total > 0 {
+		filled := int(float64(PROGRESS_BAR_WIDTH) * float64(p.Transferred) / float64(p.Total))
+		if filled > PROGRESS_BAR_WIDTH {
+			filled = PROGRESS_BAR_WIDTH
+		}
+		strBar = strings.Repeat("=", filled)
+		if filled < PROGRESS_BAR_WIDTH {
+			strBar += ">" + strings.Repeat(" ", PROGRESS_BAR_WIDTH-filled-1)
+		}
+		strPercent = fmt.Sprintf("%5.1f%%", float64(p.Transferred)*100/float64(p.Total))
+		strSize = fmt.Sprintf("%s/%s", formatBytes(float64(p.Transferred)), formatBytes(float64(p.Total)))
+	} else {
+		strBar = strings.Repeat(" ", PROGRESS_BAR_WIDTH)
+		strPercent = "  ?.?%"
+		strSize = formatBytes(float64(p.Transferred))
+	}
+	strEta := "ETA --"
+	if p.ETA >= 0 {
+		strEta = fmt.Sprintf("ETA %v", p.ETA.Round(time.Second))
+	}
+	if p.Done {
+		strEta = fmt.Sprintf("in %v", p.Elapsed.Round(time.Millisecond))
+	}
+	fmt.Fprintf(os.Stderr, "\r[%s] %s  %s  %s/s  %s\033[K", strBar, strPercent, strSize, formatBytes(p.Rate), strEta)
+	if p.Done {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// formatBytes format bytes in human readable units
+func formatBytes(n float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f%s", n, units[i])
+	}
+	return fmt.Sprintf("%.1f%s", n, units[i])
+}
+
 type Manager struct {
 	*mock.Controller
 	cfg    *mock.Config
@@ -267,7 +334,7 @@ func (m *Manager) startWebService() (err error) {
 	log.Infof("starting http server on %s", strHttpAddr)
 	//Web manager service
 	if err = http.ListenAndServe(strHttpAddr, routerMgr); err != nil { //if everything is fine, it will block this routine
-		log.Panic("listen http server [%s] error [%s]\n", strHttpAddr, err)
+		log.Panic(fmt.Sprintf("listen http server [%s] error [%s]", strHttpAddr, err))
 	}
 	return
 }
@@ -0,0 +1,145 @@
+package httpc
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v2"
+	"mime"
+	"strings"
+	"sync"
+)
+
+// Codec encode request body and decode response body of a content type
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type JsonCodec struct{}
+
+func (JsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type XmlCodec struct{}
+
+func (XmlCodec) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (XmlCodec) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+type YamlCodec struct{}
+
+func (YamlCodec) Marshal(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+func (YamlCodec) Unmarshal(data []byte, v interface{}) error {
+	return yaml.Unmarshal(data, v)
+}
+
+type MsgpackCodec struct {
+	handle *codec.MsgpackHandle
+}
+
+func NewMsgpackCodec() *MsgpackCodec {
+	handle := &codec.MsgpackHandle{}
+	handle.WriteExt = true
+	handle.RawToString = true
+	return &MsgpackCodec{handle: handle}
+}
+
+func (m *MsgpackCodec) Marshal(v interface{}) (data []byte, err error) {
+	err = codec.NewEncoderBytes(&data, m.handle).Encode(v)
+	return
+}
+
+func (m *MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return codec.NewDecoderBytes(data, m.handle).Decode(v)
+}
+
+// ProtobufCodec encode and decode protobuf messages, v must implement proto.Message
+type ProtobufCodec struct{}
+
+func (ProtobufCodec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("type %T is not a proto.Message", v)
+	}
+	return proto.Marshal(msg)
+}
+
+func (ProtobufCodec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("type %T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+var codecLocker sync.RWMutex
+var codecs = map[string]Codec{}
+
+func init() {
+	msgpack := NewMsgpackCodec()
+	RegisterCodec(JsonCodec{}, CONTENT_TYPE_NAME_APPLICATION_JSON, CONTENT_TYPE_NAME_TEXT_JSON)
+	RegisterCodec(XmlCodec{}, CONTENT_TYPE_NAME_APPLICATION_XML, CONTENT_TYPE_NAME_TEXT_XML)
+	RegisterCodec(YamlCodec{}, CONTENT_TYPE_NAME_APPLICATION_YAML, CONTENT_TYPE_NAME_APPLICATION_X_YAML, CONTENT_TYPE_NAME_TEXT_YAML)
+	RegisterCodec(msgpack, CONTENT_TYPE_NAME_APPLICATION_MSGPACK, CONTENT_TYPE_NAME_APPLICATION_X_MSGPACK, CONTENT_TYPE_NAME_APPLICATION_VND_MSGPACK)
+	RegisterCodec(ProtobufCodec{}, CONTENT_TYPE_NAME_APPLICATION_PROTOBUF, CONTENT_TYPE_NAME_APPLICATION_X_PROTOBUF)
+}
+
+// RegisterCodec register codec for content types, an existing one will be replaced
+func RegisterCodec(c Codec, contentTypes ...string) {
+	codecLocker.Lock()
+	defer codecLocker.Unlock()
+	for _, contentType := range contentTypes {
+		codecs[mediaType(contentType)] = c
+	}
+}
+
+// GetCodec returns codec of content type (parameters like charset are ignored), structured syntax
+// suffix like application/problem+json falls back to the codec of application/json, nil if not found
+func GetCodec(contentType string) Codec {
+	strMediaType := mediaType(contentType)
+	codecLocker.RLock()
+	defer codecLocker.RUnlock()
+	if c, ok := codecs[strMediaType]; ok {
+		return c
+	}
+	if idx := strings.LastIndex(strMediaType, "+"); idx >= 0 {
+		if c, ok := codecs["application/"+strMediaType[idx+1:]]; ok {
+			return c
+		}
+	}
+	return nil
+}
+
+// getCodecOrJson returns codec of content type or json codec if not found
+func getCodecOrJson(contentType string) Codec {
+	if c := GetCodec(contentType); c != nil {
+		return c
+	}
+	return JsonCodec{}
+}
+
+// mediaType returns the lower case media type of content type without parameters
+func mediaType(contentType string) string {
+	if strMediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return strMediaType
+	}
+	if idx := strings.Index(contentType, ";"); idx >= 0 {
+		contentType = contentType[:idx]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
@@ -0,0 +1,230 @@
+package httpc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/andybalholm/brotli"
+	"github.com/civet148/log"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	HEADER_KEY_ACCEPT_ENCODING  = "Accept-Encoding"
+	HEADER_KEY_CONTENT_ENCODING = "Content-Encoding"
+)
+
+const (
+	ENCODING_GZIP     = "gzip"
+	ENCODING_DEFLATE  = "deflate"
+	ENCODING_BROTLI   = "br"
+	ENCODING_ZSTD     = "zstd"
+	ENCODING_IDENTITY = "identity"
+)
+
+const (
+	DEFAULT_COMPRESS_MIN_SIZE = 1024
+)
+
+// sizer is implemented by bytes.Reader, strings.Reader and bytes.Buffer
+type sizer interface {
+	Len() int
+}
+
+// acceptEncoding returns the Accept-Encoding header value of client, empty means let net/http handle gzip implicitly
+func (c *Client) acceptEncoding() string {
+	return strings.Join(c.acceptEncodings, ", ")
+}
+
+// decodeResponse replace the response body with a decoder by Content-Encoding if the encoding is accepted by client
+func (c *Client) decodeResponse(resp *http.Response) (err error) {
+	if len(c.acceptEncodings) == 0 {
+		return nil
+	}
+	strEncoding := resp.Header.Get(HEADER_KEY_CONTENT_ENCODING)
+	if strEncoding == "" || !hasResponseBody(resp) {
+		return nil
+	}
+	//encodings are listed in the order they were applied, so decode them in reverse order
+	encodings := strings.Split(strEncoding, ",")
+	body := resp.Body
+	for i := len(encodings) - 1; i >= 0; i-- {
+		strName := strings.ToLower(strings.TrimSpace(encodings[i]))
+		if strName == "" || strName == ENCODING_IDENTITY {
+			continue
+		}
+		var reader io.ReadCloser
+		if reader, err = newDecoder(strName, body); err != nil {
+			_ = resp.Body.Close()
+			return log.Errorf("decode response body by content encoding [%s] error [%s]", strName, err)
+		}
+		body = &decodeReadCloser{reader: reader, body: body}
+	}
+	resp.Body = body
+	resp.Header.Del(HEADER_KEY_CONTENT_ENCODING)
+	resp.Header.Del(HEADER_KEY_CONTENT_LENGTH)
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// compressRequest compress request body by client's request encoding if the body size reaches the threshold.
+// the in-memory body of known size is compressed into a buffer, the body of unknown size (e.g. a file) is always
+// compressed while sending through a pipe, so it's never held in memory and can not be retried.
+// header will be cloned before setting Content-Encoding
+func (c *Client) compressRequest(header http.Header, body io.Reader) (http.Header, io.Reader, error) {
+	if c.compressEncoding == "" || body == nil || header.Get(HEADER_KEY_CONTENT_ENCODING) != "" {
+		return header, body, nil
+	}
+	if _, err := newEncoder(c.compressEncoding, ioutil.Discard); err != nil {
+		return nil, nil, err
+	}
+	if header != nil {
+		header = header.Clone()
+	} else {
+		header = http.Header{}
+	}
+	header.Set(HEADER_KEY_CONTENT_ENCODING, c.compressEncoding)
+
+	s, ok := body.(sizer)
+	if !ok {
+		return header, &compressReader{encoding: c.compressEncoding, body: body}, nil
+	}
+	if s.Len() < c.compressMinSize {
+		header.Del(HEADER_KEY_CONTENT_ENCODING)
+		return header, body, nil
+	}
+	var buf bytes.Buffer
+	writer, _ := newEncoder(c.compressEncoding, &buf)
+	if _, err := io.Copy(writer, body); err != nil {
+		_ = writer.Close()
+		return nil, nil, log.Errorf("compress request body by [%s] error [%s]", c.compressEncoding, err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, nil, log.Errorf("compress request body by [%s] error [%s]", c.compressEncoding, err)
+	}
+	return header, &buf, nil
+}
+
+func newDecoder(strEncoding string, r io.Reader) (io.ReadCloser, error) {
+	switch strEncoding {
+	case ENCODING_GZIP, "x-gzip":
+		return gzip.NewReader(r)
+	case ENCODING_DEFLATE:
+		//"deflate" should be zlib format but some servers send raw deflate data
+		br := bufio.NewReader(r)
+		if header, err := br.Peek(2); err == nil && isZlibHeader(header) {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	case ENCODING_BROTLI:
+		return ioutil.NopCloser(brotli.NewReader(r)), nil
+	case ENCODING_ZSTD:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+	return nil, log.Errorf("unsupported content encoding [%s]", strEncoding)
+}
+
+func newEncoder(strEncoding string, w io.Writer) (io.WriteCloser, error) {
+	switch strEncoding {
+	case ENCODING_GZIP:
+		return gzip.NewWriter(w), nil
+	case ENCODING_DEFLATE:
+		return zlib.NewWriter(w), nil
+	case ENCODING_BROTLI:
+		return brotli.NewWriter(w), nil
+	case ENCODING_ZSTD:
+		return zstd.NewWriter(w)
+	}
+	return nil, log.Errorf("unsupported content encoding [%s]", strEncoding)
+}
+
+// hasResponseBody check whether the response could have a body
+func hasResponseBody(resp *http.Response) bool {
+	if resp.Request != nil && resp.Request.Method == HTTP_METHOD_HEAD {
+		return false
+	}
+	return resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotModified && resp.Body != http.NoBody
+}
+
+// isZlibHeader check the 2 bytes zlib header (CMF, FLG) of RFC 1950
+func isZlibHeader(b []byte) bool {
+	return b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
+
+// compressReader compress body by a routine writing to pipe, the routine starts on first read so that nothing
+// leaks if the request is never sent, and it exits once the reader is closed
+type compressReader struct {
+	encoding string
+	body     io.Reader
+	once     sync.Once
+	reader   *io.PipeReader
+}
+
+func (r *compressReader) Read(p []byte) (int, error) {
+	r.once.Do(r.start)
+	if r.reader == nil {
+		return 0, io.ErrClosedPipe
+	}
+	return r.reader.Read(p)
+}
+
+func (r *compressReader) Close() error {
+	var started = true
+	r.once.Do(func() { started = false })
+	if !started { //closed before sending, close the body directly
+		if closer, ok := r.body.(io.Closer); ok {
+			return closer.Close()
+		}
+		return nil
+	}
+	if r.reader == nil {
+		return nil
+	}
+	return r.reader.Close()
+}
+
+func (r *compressReader) start() {
+	reader, writer := io.Pipe()
+	r.reader = reader
+	go func() {
+		encoder, err := newEncoder(r.encoding, writer)
+		if err == nil {
+			if _, err = io.Copy(encoder, r.body); err != nil {
+				_ = encoder.Close()
+			} else {
+				err = encoder.Close()
+			}
+		}
+		if closer, ok := r.body.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		_ = writer.CloseWithError(err)
+	}()
+}
+
+// decodeReadCloser close both the decoder and the underlying body
+type decodeReadCloser struct {
+	reader io.ReadCloser
+	body   io.Closer
+}
+
+func (d *decodeReadCloser) Read(p []byte) (int, error) {
+	return d.reader.Read(p)
+}
+
+func (d *decodeReadCloser) Close() error {
+	_ = d.reader.Close()
+	return d.body.Close()
+}
@@ -0,0 +1,241 @@
+package httpc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// closeNotifyReader a request body which records whether it's closed
+type closeNotifyReader struct {
+	io.Reader
+	closed chan struct{}
+}
+
+func newCloseNotifyReader(r io.Reader) *closeNotifyReader {
+	return &closeNotifyReader{Reader: r, closed: make(chan struct{})}
+}
+
+func (r *closeNotifyReader) Close() error {
+	close(r.closed)
+	return nil
+}
+
+func (r *closeNotifyReader) waitClosed(t *testing.T, strName string) {
+	select {
+	case <-r.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s: body is not closed", strName)
+	}
+}
+
+// endlessReader never reaches EOF
+type endlessReader struct{}
+
+func (endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'a'
+	}
+	return len(p), nil
+}
+
+// failReader returns error after n bytes
+type failReader struct {
+	n   int
+	err error
+}
+
+func (r *failReader) Read(p []byte) (int, error) {
+	if r.n <= 0 {
+		return 0, r.err
+	}
+	if len(p) > r.n {
+		p = p[:r.n]
+	}
+	r.n -= len(p)
+	return len(p), nil
+}
+
+func encodeBytes(t *testing.T, strEncoding string, data []byte) []byte {
+	var buf bytes.Buffer
+	w, err := newEncoder(strEncoding, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decodeBytes(strEncoding string, data []byte) ([]byte, error) {
+	r, err := newDecoder(strEncoding, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func TestCompressReader(t *testing.T) {
+	data := []byte(strings.Repeat("compress reader ", 10000))
+	for _, strEncoding := range []string{ENCODING_GZIP, ENCODING_DEFLATE, ENCODING_BROTLI, ENCODING_ZSTD} {
+		body := newCloseNotifyReader(bytes.NewReader(data))
+		encoded, err := ioutil.ReadAll(&compressReader{encoding: strEncoding, body: body})
+		if err != nil {
+			t.Fatalf("%s: read compressed body error [%s]", strEncoding, err)
+		}
+		body.waitClosed(t, strEncoding)
+		if decoded, e := decodeBytes(strEncoding, encoded); e != nil || !bytes.Equal(decoded, data) {
+			t.Errorf("%s: decoded [%d bytes] error [%v], expect the original content", strEncoding, len(decoded), e)
+		}
+	}
+}
+
+func TestCompressReaderClose(t *testing.T) {
+	//closed before read, the routine is never started
+	body := newCloseNotifyReader(bytes.NewReader([]byte("data")))
+	r := &compressReader{encoding: ENCODING_GZIP, body: body}
+	if err := r.Close(); err != nil {
+		t.Fatalf("close before read error [%s]", err)
+	}
+	body.waitClosed(t, "close before read")
+	if _, err := r.Read(make([]byte, 10)); err != io.ErrClosedPipe {
+		t.Errorf("read after close error [%v], expect [%v]", err, io.ErrClosedPipe)
+	}
+
+	//closed in the middle of an endless body, the routine must exit and close the body
+	body = newCloseNotifyReader(endlessReader{})
+	r = &compressReader{encoding: ENCODING_GZIP, body: body}
+	if _, err := io.ReadFull(r, make([]byte, 100)); err != nil {
+		t.Fatalf("read endless body error [%s]", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("close mid-stream error [%s]", err)
+	}
+	body.waitClosed(t, "close mid-stream")
+	if _, err := r.Read(make([]byte, 10)); err != io.ErrClosedPipe {
+		t.Errorf("read after close error [%v], expect [%v]", err, io.ErrClosedPipe)
+	}
+}
+
+func TestCompressReaderError(t *testing.T) {
+	errBody := errors.New("disk failure")
+	_, err := ioutil.ReadAll(&compressReader{encoding: ENCODING_ZSTD, body: &failReader{n: 100000, err: errBody}})
+	if !errors.Is(err, errBody) {
+		t.Errorf("read error [%v], expect body error [%v]", err, errBody)
+	}
+	_, err = ioutil.ReadAll(&compressReader{encoding: "unknown", body: bytes.NewReader([]byte("data"))})
+	if err == nil {
+		t.Errorf("read by unsupported encoding succeeded, expect error")
+	}
+	c := NewClient(&Option{CompressRequest: "unknown"})
+	if _, _, err = c.compressRequest(nil, bytes.NewReader([]byte("data"))); err == nil {
+		t.Errorf("compress request by unsupported encoding succeeded, expect error")
+	}
+}
+
+// echoServer decode request body by Content-Encoding and send it back encoded by the same encoding if accepted
+type echoServer struct {
+	t        *testing.T
+	encoding string //Content-Encoding of last request, "-" if none
+	accepted string //Accept-Encoding of last request
+}
+
+func (s *echoServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, _ := ioutil.ReadAll(r.Body)
+	s.encoding, s.accepted = r.Header.Get(HEADER_KEY_CONTENT_ENCODING), r.Header.Get(HEADER_KEY_ACCEPT_ENCODING)
+	if s.encoding != "" {
+		var err error
+		if data, err = decodeBytes(s.encoding, data); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	} else {
+		s.encoding = "-"
+	}
+	if strings.Contains(s.accepted, s.encoding) {
+		w.Header().Set(HEADER_KEY_CONTENT_ENCODING, s.encoding)
+		data = encodeBytes(s.t, s.encoding, data)
+	}
+	_, _ = w.Write(data)
+}
+
+func TestCompressRoundTrip(t *testing.T) {
+	small := []byte("small body")
+	large := []byte(strings.Repeat("large body ", 1000))
+	var cases = []struct {
+		name     string
+		encoding string
+		body     func() io.Reader
+		expect   []byte
+		sent     string //Content-Encoding received by server
+	}{
+		{"gzip", ENCODING_GZIP, func() io.Reader { return bytes.NewReader(large) }, large, ENCODING_GZIP},
+		{"br", ENCODING_BROTLI, func() io.Reader { return bytes.NewReader(large) }, large, ENCODING_BROTLI},
+		{"zstd", ENCODING_ZSTD, func() io.Reader { return bytes.NewReader(large) }, large, ENCODING_ZSTD},
+		{"under threshold", ENCODING_GZIP, func() io.Reader { return bytes.NewReader(small) }, small, "-"},
+		{"unknown size", ENCODING_ZSTD, func() io.Reader { return io.MultiReader(bytes.NewReader(small)) }, small, ENCODING_ZSTD},
+	}
+	for _, c := range cases {
+		srv := &echoServer{t: t}
+		ts := httptest.NewServer(srv)
+		cli := NewClient(&Option{
+			AcceptEncoding:  []string{c.encoding},
+			CompressRequest: c.encoding,
+			CompressMinSize: 100,
+		})
+		r, err := cli.SendRequest(nil, HTTP_METHOD_POST, ts.URL, c.body())
+		if err != nil {
+			t.Fatalf("%s: send request error [%s]", c.name, err)
+		}
+		if r.StatusCode != http.StatusOK || !bytes.Equal(r.Body, c.expect) {
+			t.Errorf("%s: status [%d] body [%d bytes], expect the original content", c.name, r.StatusCode, len(r.Body))
+		}
+		if srv.encoding != c.sent || srv.accepted != c.encoding {
+			t.Errorf("%s: server received Content-Encoding [%s] Accept-Encoding [%s], expect [%s] [%s]",
+				c.name, srv.encoding, srv.accepted, c.sent, c.encoding)
+		}
+		if c.sent != "-" && r.Header.Get(HEADER_KEY_CONTENT_ENCODING) != "" {
+			t.Errorf("%s: Content-Encoding of decoded response is not removed", c.name)
+		}
+		ts.Close()
+	}
+}
+
+func TestDecodeResponseMultiple(t *testing.T) {
+	data := []byte(strings.Repeat("multiple encodings ", 1000))
+	encoded := encodeBytes(t, ENCODING_BROTLI, encodeBytes(t, ENCODING_GZIP, data)) //gzip applied first
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HEADER_KEY_CONTENT_ENCODING, "gzip, identity, br")
+		_, _ = w.Write(encoded)
+	}))
+	defer ts.Close()
+
+	r, err := NewClient(&Option{AcceptEncoding: []string{ENCODING_GZIP, ENCODING_BROTLI}}).SendRequest(nil, HTTP_METHOD_GET, ts.URL, nil)
+	if err != nil {
+		t.Fatalf("send request error [%s]", err)
+	}
+	if !bytes.Equal(r.Body, data) {
+		t.Errorf("decoded body [%d bytes], expect the original content", len(r.Body))
+	}
+	if !r.decoded {
+		t.Errorf("response is not marked as decoded")
+	}
+
+	body := newCloseNotifyReader(bytes.NewReader(encoded))
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{HEADER_KEY_CONTENT_ENCODING: {"br, compress"}}, Body: body}
+	if err = NewClient(&Option{AcceptEncoding: []string{ENCODING_BROTLI}}).decodeResponse(resp); err == nil {
+		t.Errorf("decode unsupported content encoding succeeded, expect error")
+	}
+	body.waitClosed(t, "unsupported content encoding")
+}
@@ -0,0 +1,227 @@
+package httpc
+
+import (
+	"encoding/json"
+	"github.com/civet148/log"
+	"golang.org/x/net/publicsuffix"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileCookieJar a public suffix aware cookie jar which saves cookies to file on every change
+// and reloads them when created, so the cookies could be shared across processes run one by one
+type FileCookieJar struct {
+	jar     *cookiejar.Jar
+	strFile string
+	locker  sync.Mutex
+	entries map[string]*cookieEntry
+}
+
+type cookieEntry struct {
+	Url    string       `json:"url"`
+	Cookie *http.Cookie `json:"cookie"`
+}
+
+// NewCookieJar make a public suffix aware in-memory cookie jar
+func NewCookieJar() http.CookieJar {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return jar
+}
+
+// NewFileCookieJar make a public suffix aware cookie jar persisted to file, the cookies in file will be loaded if exists
+func NewFileCookieJar(strFile string) (j *FileCookieJar, err error) {
+	var jar *cookiejar.Jar
+	if jar, err = cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List}); err != nil {
+		return nil, err
+	}
+	j = &FileCookieJar{
+		jar:     jar,
+		strFile: strFile,
+		entries: make(map[string]*cookieEntry),
+	}
+	if err = j.load(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// SetCookies handles the receipt of the cookies in a reply for the given URL and saves them to file
+func (j *FileCookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+
+	j.locker.Lock()
+	defer j.locker.Unlock()
+	for _, cookie := range cookies {
+		key := cookieKey(u, cookie)
+		if cookie.MaxAge < 0 || (!cookie.Expires.IsZero() && cookie.Expires.Before(time.Now())) {
+			delete(j.entries, key)
+			continue
+		}
+		if cookie.MaxAge > 0 && cookie.Expires.IsZero() {
+			c := *cookie
+			c.Expires = time.Now().Add(time.Duration(c.MaxAge) * time.Second)
+			c.MaxAge = 0
+			cookie = &c
+		}
+		j.entries[key] = &cookieEntry{
+			Url:    u.String(),
+			Cookie: cookie,
+		}
+	}
+	if err := j.save(); err != nil {
+		log.Errorf("save cookies to file [%s] error [%s]", j.strFile, err)
+	}
+}
+
+// Cookies returns the cookies to send in a request for the given URL
+func (j *FileCookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// Save save cookies to file
+func (j *FileCookieJar) Save() error {
+	j.locker.Lock()
+	defer j.locker.Unlock()
+	return j.save()
+}
+
+func (j *FileCookieJar) load() (err error) {
+	var data []byte
+	if data, err = ioutil.ReadFile(j.strFile); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return log.Errorf("read cookie file [%s] error [%s]", j.strFile, err)
+	}
+	var entries []*cookieEntry
+	if err = json.Unmarshal(data, &entries); err != nil {
+		return log.Errorf("unmarshal cookie file [%s] error [%s]", j.strFile, err)
+	}
+	for _, entry := range entries {
+		if entry.Cookie == nil {
+			continue
+		}
+		if !entry.Cookie.Expires.IsZero() && entry.Cookie.Expires.Before(time.Now()) {
+			continue
+		}
+		var u *url.URL
+		if u, err = url.Parse(entry.Url); err != nil {
+			log.Warnf("cookie url [%s] parse error [%s]", entry.Url, err)
+			continue
+		}
+		j.jar.SetCookies(u, []*http.Cookie{entry.Cookie})
+		j.entries[cookieKey(u, entry.Cookie)] = entry
+	}
+	return nil
+}
+
+// save write cookies to a temp file and rename it to cookie file, the caller must hold the lock
+func (j *FileCookieJar) save() (err error) {
+	var entries = make([]*cookieEntry, 0, len(j.entries))
+	for _, entry := range j.entries {
+		entries = append(entries, entry)
+	}
+	var data []byte
+	if data, err = json.MarshalIndent(entries, "", "  "); err != nil {
+		return err
+	}
+	var tmp *os.File
+	if tmp, err = ioutil.TempFile(filepath.Dir(j.strFile), filepath.Base(j.strFile)+".tmp*"); err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Chmod(0600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), j.strFile)
+}
+
+// cookieKey identity of cookie by domain, path and name
+func cookieKey(u *url.URL, cookie *http.Cookie) string {
+	domain := strings.ToLower(strings.TrimPrefix(cookie.Domain, "."))
+	if domain == "" {
+		domain = strings.ToLower(u.Hostname())
+	}
+	path := cookie.Path
+	if path == "" { //default path of RFC 6265 section 5.1.4
+		path = "/"
+		if idx := strings.LastIndex(u.Path, "/"); idx > 0 {
+			path = u.Path[:idx]
+		}
+	}
+	return domain + ";" + path + ";" + cookie.Name
+}
+
+// newClientCookieJar make cookie jar of client by option, nil means cookie disabled
+func newClientCookieJar(opt *Option) http.CookieJar {
+	if opt.CookieJar != nil {
+		return opt.CookieJar
+	}
+	if opt.CookieFile != "" {
+		jar, err := NewFileCookieJar(opt.CookieFile)
+		if err == nil {
+			return jar
+		}
+		log.Errorf("load cookie file [%s] error [%s], use in-memory cookie jar instead", opt.CookieFile, err)
+		return NewCookieJar()
+	}
+	if opt.EnableCookie {
+		return NewCookieJar()
+	}
+	return nil
+}
+
+// WithCookieJar set cookie jar of client, it should be called before sending any request
+func (c *Client) WithCookieJar(jar http.CookieJar) *Client {
+	c.locker.Lock()
+	c.cli.Jar = jar
+	c.locker.Unlock()
+	return c
+}
+
+// Cookies returns the cookies of url in client's cookie jar
+func (c *Client) Cookies(strUrl string) []*http.Cookie {
+	c.locker.RLock()
+	jar := c.cli.Jar
+	c.locker.RUnlock()
+	if jar == nil {
+		return nil
+	}
+	u, err := url.Parse(c.resolveUrl(strUrl))
+	if err != nil {
+		log.Errorf("parse url [%s] error [%s]", strUrl, err)
+		return nil
+	}
+	return jar.Cookies(u)
+}
+
+// SetCookies set cookies of url into client's cookie jar
+func (c *Client) SetCookies(strUrl string, cookies ...*http.Cookie) error {
+	c.locker.RLock()
+	jar := c.cli.Jar
+	c.locker.RUnlock()
+	if jar == nil {
+		return log.Errorf("cookie jar is not enabled")
+	}
+	u, err := url.Parse(c.resolveUrl(strUrl))
+	if err != nil {
+		return log.Errorf("parse url [%s] error [%s]", strUrl, err)
+	}
+	jar.SetCookies(u, cookies)
+	return nil
+}
@@ -0,0 +1,95 @@
+package httpc
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	URL_SCHEME_UNIX   = "unix"
+	UNIX_HOST_SUFFIX  = ".unix.invalid" //suffix of the placeholder host after the hex encoded socket path, ".invalid" never resolves
+	UNIX_HOST_HEADER  = "localhost"     //Host header of the requests over unix socket
+	NETWORK_NAME_UNIX = "unix"
+)
+
+// DialFunc dial a connection to address on the named network (same as http.Transport.DialContext)
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// newDialFunc make dial function of transport by option, the priority is unix url > UnixSocket > Dialer > net.Dialer,
+// the host overrides and custom resolver are applied before Dialer and net.Dialer
+func newDialFunc(opt *Option, dialer *net.Dialer) DialFunc {
+	var dial = DialFunc(dialer.DialContext)
+	if opt.Dialer != nil {
+		dial = opt.Dialer
+	}
+	dial = withResolve(opt, dial)
+	if opt.UnixSocket != "" {
+		strSocket := opt.UnixSocket
+		dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, NETWORK_NAME_UNIX, strSocket)
+		}
+	}
+	var next = dial
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if strSocket, ok := unixSocketOfAddr(addr); ok {
+			return dialer.DialContext(ctx, NETWORK_NAME_UNIX, strSocket)
+		}
+		return next(ctx, network, addr)
+	}
+}
+
+// withoutUnixProxy never use proxy for requests over unix socket
+func withoutUnixProxy(proxy ProxyFunc) ProxyFunc {
+	if proxy == nil {
+		return nil
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		if isUnixHost(req.URL.Host) {
+			return nil, nil
+		}
+		return proxy(req)
+	}
+}
+
+// rewriteUnixUrl rewrite url like unix:///var/run/docker.sock:/containers/json to
+// http://<hex encoded socket path>.unix.invalid/containers/json, the other urls will be returned directly
+func rewriteUnixUrl(strUrl string) string {
+	if !strings.HasPrefix(strUrl, URL_SCHEME_UNIX+"://") {
+		return strUrl
+	}
+	strSocket := strings.TrimPrefix(strUrl, URL_SCHEME_UNIX+"://")
+	strPath := "/"
+	if idx := strings.Index(strSocket, ":"); idx >= 0 {
+		strSocket, strPath = strSocket[:idx], strSocket[idx+1:]
+		if !strings.HasPrefix(strPath, "/") {
+			strPath = "/" + strPath
+		}
+	}
+	return "http://" + hex.EncodeToString([]byte(strSocket)) + UNIX_HOST_SUFFIX + strPath
+}
+
+// unixSocketOfAddr decode socket path from dial address of a rewritten unix url
+func unixSocketOfAddr(addr string) (string, bool) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if !strings.HasSuffix(host, UNIX_HOST_SUFFIX) {
+		return "", false
+	}
+	path, err := hex.DecodeString(strings.TrimSuffix(host, UNIX_HOST_SUFFIX))
+	if err != nil || len(path) == 0 {
+		return "", false
+	}
+	return string(path), true
+}
+
+// isUnixHost check whether the host (with or without port) is the placeholder host of a rewritten unix url
+func isUnixHost(host string) bool {
+	_, ok := unixSocketOfAddr(host)
+	return ok
+}
@@ -0,0 +1,57 @@
+package httpc
+
+import (
+	"testing"
+)
+
+func TestRewriteUnixUrl(t *testing.T) {
+	var cases = []struct {
+		url    string
+		expect string
+	}{
+		{"unix:///var/run/docker.sock:/containers/json", "http://2f7661722f72756e2f646f636b65722e736f636b.unix.invalid/containers/json"},
+		{"unix:///var/run/docker.sock:info", "http://2f7661722f72756e2f646f636b65722e736f636b.unix.invalid/info"},
+		{"unix:///var/run/docker.sock", "http://2f7661722f72756e2f646f636b65722e736f636b.unix.invalid/"},
+		{"http://example.com/unix", "http://example.com/unix"},
+	}
+	for _, c := range cases {
+		if strUrl := rewriteUnixUrl(c.url); strUrl != c.expect {
+			t.Errorf("rewriteUnixUrl(%q) = %q, expect %q", c.url, strUrl, c.expect)
+		}
+	}
+}
+
+func TestUnixSocketOfAddr(t *testing.T) {
+	var cases = []struct {
+		addr   string
+		socket string
+		ok     bool
+	}{
+		{"2f746d702f612e736f636b.unix.invalid:80", "/tmp/a.sock", true},
+		{"2f746d702f612e736f636b.unix.invalid", "/tmp/a.sock", true},
+		{".unix.invalid:80", "", false},
+		{"zz.unix.invalid:80", "", false},
+		{"unix-cafe:80", "", false}, //a real host name which looks like hex must not be dialed as socket
+		{"cafe.unix.example.com:80", "", false},
+		{"example.com:80", "", false},
+	}
+	for _, c := range cases {
+		socket, ok := unixSocketOfAddr(c.addr)
+		if socket != c.socket || ok != c.ok {
+			t.Errorf("unixSocketOfAddr(%q) = (%q, %v), expect (%q, %v)", c.addr, socket, ok, c.socket, c.ok)
+		}
+		if isUnixHost(c.addr) != c.ok {
+			t.Errorf("isUnixHost(%q) = %v, expect %v", c.addr, !c.ok, c.ok)
+		}
+	}
+}
+
+func TestRewriteUnixUrlRoundTrip(t *testing.T) {
+	const strSocket = "/var/run/docker.sock"
+	strUrl := rewriteUnixUrl(URL_SCHEME_UNIX + "://" + strSocket + ":/version")
+	strHost := strUrl[len("http://"):]
+	strHost = strHost[:len(strHost)-len("/version")]
+	if socket, ok := unixSocketOfAddr(strHost + ":80"); !ok || socket != strSocket {
+		t.Errorf("unixSocketOfAddr of rewritten url [%s] = (%q, %v), expect (%q, true)", strUrl, socket, ok, strSocket)
+	}
+}
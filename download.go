@@ -0,0 +1,304 @@
+package httpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/civet148/log"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HEADER_KEY_RANGE         = "Range"
+	HEADER_KEY_IF_RANGE      = "If-Range"
+	HEADER_KEY_CONTENT_RANGE = "Content-Range"
+	HEADER_KEY_ACCEPT_RANGES = "Accept-Ranges"
+)
+
+const (
+	DOWNLOAD_PART_SUFFIX = ".part"      //suffix of the partial file being downloaded
+	DOWNLOAD_META_SUFFIX = ".part.meta" //suffix of the file which keeps the validator of partial file
+)
+
+// partMeta the url and validator (ETag or Last-Modified) of a partial file, the partial file can only be
+// resumed by the same url and validator, otherwise it's downloaded from the beginning
+type partMeta struct {
+	Url          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// saveFile download url to file and resume from the partial file left by last interrupted download,
+// the data is written to strFilePath.part and renamed to strFilePath when completed
+func (c *Client) saveFile(ctx context.Context, strUrl, strFilePath string, queries ...url.Values) (size int64, err error) {
+	strPart := strFilePath + DOWNLOAD_PART_SUFFIX
+	strMeta := strFilePath + DOWNLOAD_META_SUFFIX
+	strFullUrl := c.makeQueryUrl(c.resolveUrl(strUrl), queries...)
+
+	var offset int64
+	var validator string
+	if meta := loadPartMeta(strMeta); meta != nil && meta.Url == strFullUrl {
+		if validator = meta.validator(); validator != "" {
+			if fi, e := os.Stat(strPart); e == nil {
+				offset = fi.Size()
+			}
+		}
+	}
+	if offset > 0 {
+		if size, err = c.resumeFile(ctx, strFullUrl, strPart, strMeta, offset, validator); err != errRangeIgnored {
+			return size, c.finishFile(strPart, strMeta, strFilePath, err)
+		}
+		log.Warnf("url [%s] partial file [%s] could not be resumed, download from the beginning", strUrl, strPart)
+	}
+	size, err = c.restartFile(ctx, strFullUrl, strPart, strMeta)
+	return size, c.finishFile(strPart, strMeta, strFilePath, err)
+}
+
+// errRangeIgnored the range request could not be satisfied, the partial file should be dropped
+var errRangeIgnored = errors.New("range request ignored")
+
+// resumeFile append the rest of url content from offset to partial file, the partial file is overwritten if the server
+// sent the whole content. errRangeIgnored returned if the range is not satisfiable
+func (c *Client) resumeFile(ctx context.Context, strUrl, strPart, strMeta string, offset int64, validator string) (size int64, err error) {
+	header := c.downloadHeader()
+	header.Set(HEADER_KEY_RANGE, fmt.Sprintf("bytes=%d-", offset))
+	header.Set(HEADER_KEY_IF_RANGE, validator)
+
+	var r *Response
+	if r, err = c.SendRequestContext(withStreamContext(ctx), header, HTTP_METHOD_GET, strUrl, nil); err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			return c.rangeNotSatisfiable(ctx, httpErr.Header, strPart, offset)
+		}
+		return 0, err
+	}
+	defer r.stream.Close()
+	switch r.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusRequestedRangeNotSatisfiable:
+		return c.rangeNotSatisfiable(ctx, r.Header, strPart, offset)
+	case http.StatusOK: //resource changed or range not supported
+		log.Warnf("url [%s] range ignored by server, download from the beginning", strUrl)
+		return c.writePartFile(ctx, r, strUrl, strPart, strMeta)
+	default:
+		return 0, newStatusError(HTTP_METHOD_GET, strUrl, r)
+	}
+	start, total, ok := parseContentRange(r.Header.Get(HEADER_KEY_CONTENT_RANGE))
+	if !ok || start != offset {
+		return 0, errRangeIgnored
+	}
+	log.Debugf("url [%s] resume partial file [%s] from offset [%d]", strUrl, strPart, offset)
+	verifier := c.newVerifier(ctx, r.Header, true, r.decoded)
+	if err = verifier.feedFile(strPart); err != nil {
+		return 0, err
+	}
+	var f *os.File
+	if f, err = os.OpenFile(strPart, os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	tracker := c.newProgress(ctx, strUrl, false, offset, total)
+	var written int64
+	if written, err = io.Copy(f, verifier.reader(tracker.reader(r.stream, false))); err != nil {
+		return offset + written, err
+	}
+	if err = verifier.verify(); err != nil {
+		return offset + written, err
+	}
+	tracker.finish()
+	return offset + written, nil
+}
+
+// restartFile download the whole url content to partial file and save the validator for resuming
+func (c *Client) restartFile(ctx context.Context, strUrl, strPart, strMeta string) (size int64, err error) {
+	var r *Response
+	if r, err = c.SendRequestContext(withStreamContext(ctx), c.downloadHeader(), HTTP_METHOD_GET, strUrl, nil); err != nil {
+		return 0, err
+	}
+	defer r.stream.Close()
+	if !isSuccessStatus(r.StatusCode) {
+		return 0, newStatusError(HTTP_METHOD_GET, strUrl, r)
+	}
+	return c.writePartFile(ctx, r, strUrl, strPart, strMeta)
+}
+
+// writePartFile write the whole content of response to partial file and save the validator for resuming
+func (c *Client) writePartFile(ctx context.Context, r *Response, strUrl, strPart, strMeta string) (size int64, err error) {
+	_ = os.Remove(strMeta)
+	verifier := c.newVerifier(ctx, r.Header, false, r.decoded)
+	var f *os.File
+	if f, err = os.OpenFile(strPart, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	meta := &partMeta{
+		Url:          strUrl,
+		ETag:         r.ETag(),
+		LastModified: r.Header.Get(HEADER_KEY_LAST_MODIFIED),
+	}
+	if meta.validator() != "" {
+		if err = savePartMeta(strMeta, meta); err != nil {
+			log.Warnf("save partial file meta [%s] error [%s]", strMeta, err)
+		}
+	}
+	tracker := c.newProgress(ctx, strUrl, false, 0, contentLength(r.Header))
+	if size, err = io.Copy(f, verifier.reader(tracker.reader(r.stream, false))); err != nil {
+		return size, err
+	}
+	if err = verifier.verify(); err != nil {
+		return size, err
+	}
+	tracker.finish()
+	return size, nil
+}
+
+// finishFile flush the partial file to disk and rename it to destination if download completed, so the destination
+// is either the complete content or untouched. if download failed, the partial file is kept for resuming only if it
+// could be resumed (the meta file exists), and it's always removed if checksum mismatched
+func (c *Client) finishFile(strPart, strMeta, strFilePath string, err error) error {
+	if err != nil {
+		var checksumErr *ChecksumError
+		if _, e := os.Stat(strMeta); e != nil || errors.As(err, &checksumErr) {
+			_ = os.Remove(strPart)
+			_ = os.Remove(strMeta)
+		}
+		return err
+	}
+	if err = syncFile(strPart); err != nil {
+		return log.Errorf("sync file [%s] error [%s]", strPart, err)
+	}
+	var modTime time.Time
+	c.locker.RLock()
+	preserveModTime := c.preserveModTime
+	c.locker.RUnlock()
+	if meta := loadPartMeta(strMeta); meta != nil && preserveModTime {
+		modTime, _ = http.ParseTime(meta.LastModified)
+	}
+	if err = os.Rename(strPart, strFilePath); err != nil {
+		return log.Errorf("rename [%s] to [%s] error [%s]", strPart, strFilePath, err)
+	}
+	_ = syncFile(filepath.Dir(strFilePath)) //persist the rename, not supported on some platforms
+	_ = os.Remove(strMeta)
+	if !modTime.IsZero() {
+		if err = os.Chtimes(strFilePath, time.Now(), modTime); err != nil {
+			log.Warnf("set modification time of file [%s] error [%s]", strFilePath, err)
+		}
+	}
+	return nil
+}
+
+// WithPreserveModTime set the modification time of file saved by SaveFile to the Last-Modified response header
+func (c *Client) WithPreserveModTime(preserve bool) *Client {
+	c.locker.Lock()
+	c.preserveModTime = preserve
+	c.locker.Unlock()
+	return c
+}
+
+// syncFile commit the content of file or directory to stable storage
+func syncFile(strPath string) error {
+	f, err := os.Open(strPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// downloadHeader clone client's headers and ask for the raw bytes, so the ranges are offsets of the saved file
+func (c *Client) downloadHeader() http.Header {
+	header := c.mergeHeader(nil)
+	header.Set(HEADER_KEY_ACCEPT_ENCODING, ENCODING_IDENTITY)
+	return header
+}
+
+// contentLength returns the Content-Length header value, -1 if unknown
+func contentLength(header http.Header) int64 {
+	n, err := strconv.ParseInt(header.Get(HEADER_KEY_CONTENT_LENGTH), 10, 64)
+	if err != nil || n < 0 {
+		return -1
+	}
+	return n
+}
+
+// rangeNotSatisfiable the partial file is complete if its size equals the content length, otherwise drop it
+func (c *Client) rangeNotSatisfiable(ctx context.Context, header http.Header, strPart string, offset int64) (int64, error) {
+	if _, total, ok := parseContentRange(header.Get(HEADER_KEY_CONTENT_RANGE)); !ok || total != offset {
+		return 0, errRangeIgnored
+	}
+	verifier := c.newVerifier(ctx, header, true, false)
+	if err := verifier.feedFile(strPart); err != nil {
+		return 0, err
+	}
+	return offset, verifier.verify()
+}
+
+func (m *partMeta) validator() string {
+	if m.ETag != "" && !strings.HasPrefix(m.ETag, "W/") { //If-Range requires a strong validator
+		return m.ETag
+	}
+	return m.LastModified
+}
+
+func loadPartMeta(strMeta string) *partMeta {
+	data, err := ioutil.ReadFile(strMeta)
+	if err != nil {
+		return nil
+	}
+	var meta partMeta
+	if err = json.Unmarshal(data, &meta); err != nil {
+		log.Warnf("unmarshal partial file meta [%s] error [%s]", strMeta, err)
+		return nil
+	}
+	return &meta
+}
+
+func savePartMeta(strMeta string, meta *partMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(strMeta, data, 0644)
+}
+
+// parseContentRange parse Content-Range header value like "bytes 100-199/1000" or "bytes */1000",
+// the total is -1 if unknown
+func parseContentRange(strValue string) (start, total int64, ok bool) {
+	strValue = strings.TrimSpace(strValue)
+	if !strings.HasPrefix(strValue, "bytes ") {
+		return 0, 0, false
+	}
+	strValue = strings.TrimSpace(strings.TrimPrefix(strValue, "bytes "))
+	idx := strings.Index(strValue, "/")
+	if idx < 0 {
+		return 0, 0, false
+	}
+	strRange, strTotal := strValue[:idx], strValue[idx+1:]
+	total = -1
+	if strTotal != "*" {
+		var err error
+		if total, err = strconv.ParseInt(strTotal, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	if strRange == "*" {
+		return 0, total, true
+	}
+	if idx = strings.Index(strRange, "-"); idx < 0 {
+		return 0, 0, false
+	}
+	var err error
+	if start, err = strconv.ParseInt(strRange[:idx], 10, 64); err != nil {
+		return 0, 0, false
+	}
+	return start, total, true
+}
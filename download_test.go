@@ -0,0 +1,208 @@
+package httpc
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseContentRange(t *testing.T) {
+	var cases = []struct {
+		value string
+		start int64
+		total int64
+		ok    bool
+	}{
+		{"bytes 100-199/1000", 100, 1000, true},
+		{"bytes 0-0/1", 0, 1, true},
+		{"bytes 100-199/*", 100, -1, true},
+		{"bytes */1000", 0, 1000, true},
+		{" bytes  100-199/1000 ", 100, 1000, true},
+		{"bytes 100/1000", 0, 0, false},
+		{"bytes 100-199", 0, 0, false},
+		{"bytes x-199/1000", 0, 0, false},
+		{"bytes 100-199/x", 0, 0, false},
+		{"items 100-199/1000", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, c := range cases {
+		start, total, ok := parseContentRange(c.value)
+		if start != c.start || total != c.total || ok != c.ok {
+			t.Errorf("parseContentRange(%q) = (%d, %d, %v), expect (%d, %d, %v)", c.value, start, total, ok, c.start, c.total, c.ok)
+		}
+	}
+}
+
+// rangeServer serve content by http.ServeContent which supports Range and If-Range, the Range headers received are recorded
+type rangeServer struct {
+	etag    string
+	content []byte
+	locker  sync.Mutex
+	ranges  []string
+}
+
+func (s *rangeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.locker.Lock()
+	s.ranges = append(s.ranges, r.Header.Get(HEADER_KEY_RANGE))
+	s.locker.Unlock()
+	w.Header().Set(HEADER_KEY_ETAG, s.etag)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(s.content))
+}
+
+func TestSaveFileResume(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 1000))
+	var cases = []struct {
+		name    string
+		etag    string //etag of server, the partial file was saved with etag "v1"
+		offset  int    //size of partial file
+		expects string //the Range header expected
+	}{
+		{"resume", `"v1"`, 4000, "bytes=4000-"},
+		{"changed", `"v2"`, 4000, "bytes=4000-"},
+		{"complete", `"v1"`, len(content), "bytes=10000-"},
+	}
+	for _, c := range cases {
+		srv := &rangeServer{etag: c.etag, content: content}
+		ts := httptest.NewServer(srv)
+		strDir, err := ioutil.TempDir("", "httpc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		strFile := filepath.Join(strDir, "data.bin")
+		strUrl := ts.URL + "/data.bin"
+		partial := append([]byte{}, content[:c.offset]...)
+		if c.etag != `"v1"` {
+			partial = bytes.Repeat([]byte("x"), c.offset) //stale partial file of the old content
+		}
+		if err = ioutil.WriteFile(strFile+DOWNLOAD_PART_SUFFIX, partial, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err = savePartMeta(strFile+DOWNLOAD_META_SUFFIX, &partMeta{Url: strUrl, ETag: `"v1"`}); err != nil {
+			t.Fatal(err)
+		}
+
+		size, err := NewClient().SaveFile(strUrl, strFile)
+		if err != nil {
+			t.Errorf("%s: save file error [%s]", c.name, err)
+		} else if size != int64(len(content)) {
+			t.Errorf("%s: save file size [%d], expect [%d]", c.name, size, len(content))
+		}
+		if data, e := ioutil.ReadFile(strFile); e != nil || !bytes.Equal(data, content) {
+			t.Errorf("%s: saved file [%d bytes] error [%v] mismatched content", c.name, len(data), e)
+		}
+		if len(srv.ranges) == 0 || srv.ranges[0] != c.expects {
+			t.Errorf("%s: range headers %q, expect first [%s]", c.name, srv.ranges, c.expects)
+		}
+		for _, strPath := range []string{strFile + DOWNLOAD_PART_SUFFIX, strFile + DOWNLOAD_META_SUFFIX} {
+			if _, e := os.Stat(strPath); e == nil {
+				t.Errorf("%s: file [%s] is not removed", c.name, strPath)
+			}
+		}
+		ts.Close()
+		_ = os.RemoveAll(strDir)
+	}
+}
+
+func TestSaveFileFailure(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 1000))
+	var cases = []struct {
+		name     string
+		handler  http.HandlerFunc
+		existing bool //the destination file exists before download
+		part     bool //the partial file is kept for resuming
+	}{
+		{"not found", func(w http.ResponseWriter, r *http.Request) {
+			http.NotFound(w, r)
+		}, false, false},
+		{"not found existing", func(w http.ResponseWriter, r *http.Request) {
+			http.NotFound(w, r)
+		}, true, false},
+		{"cut with validator", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(HEADER_KEY_ETAG, `"v1"`)
+			writeCutBody(w, content)
+		}, false, true},
+		{"cut without validator", func(w http.ResponseWriter, r *http.Request) {
+			writeCutBody(w, content)
+		}, true, false},
+	}
+	for _, c := range cases {
+		ts := httptest.NewServer(c.handler)
+		strDir, err := ioutil.TempDir("", "httpc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		strFile := filepath.Join(strDir, "data.bin")
+		if c.existing {
+			if err = ioutil.WriteFile(strFile, []byte("old"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err = NewClient().SaveFile(ts.URL, strFile); err == nil {
+			t.Errorf("%s: save file succeeded, expect error", c.name)
+		}
+		data, e := ioutil.ReadFile(strFile)
+		if c.existing && string(data) != "old" {
+			t.Errorf("%s: existing file is overwritten [%s] error [%v]", c.name, data, e)
+		}
+		if !c.existing && e == nil {
+			t.Errorf("%s: file [%s] exists after failure", c.name, strFile)
+		}
+		fi, e := os.Stat(strFile + DOWNLOAD_PART_SUFFIX)
+		if c.part && (e != nil || fi.Size() != 4000) {
+			t.Errorf("%s: partial file [%v] error [%v], expect 4000 bytes kept", c.name, fi, e)
+		}
+		if !c.part && e == nil {
+			t.Errorf("%s: partial file is kept without meta", c.name)
+		}
+		if _, e = os.Stat(strFile + DOWNLOAD_META_SUFFIX); (e == nil) != c.part {
+			t.Errorf("%s: meta file exists [%v], expect [%v]", c.name, e == nil, c.part)
+		}
+		ts.Close()
+		_ = os.RemoveAll(strDir)
+	}
+}
+
+// writeCutBody declare the full content length but send the first 4000 bytes only, then abort the connection
+func writeCutBody(w http.ResponseWriter, content []byte) {
+	w.Header().Set(HEADER_KEY_CONTENT_LENGTH, strconv.Itoa(len(content)))
+	_, _ = w.Write(content[:4000])
+	w.(http.Flusher).Flush()
+	panic(http.ErrAbortHandler)
+}
+
+func TestSaveFilePreserveModTime(t *testing.T) {
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HEADER_KEY_LAST_MODIFIED, modTime.Format(http.TimeFormat))
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+	strDir, err := ioutil.TempDir("", "httpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(strDir)
+
+	for _, preserve := range []bool{true, false} {
+		strFile := filepath.Join(strDir, fmt.Sprintf("data-%v.bin", preserve))
+		if _, err = NewClient().WithPreserveModTime(preserve).SaveFile(ts.URL, strFile); err != nil {
+			t.Fatalf("save file error [%s]", err)
+		}
+		fi, err := os.Stat(strFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if preserve != fi.ModTime().Equal(modTime) {
+			t.Errorf("preserve [%v] modification time [%v], Last-Modified [%v]", preserve, fi.ModTime(), modTime)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package httpc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+const (
+	MAX_HTTP_ERROR_BODY_SIZE = 4096 //max size of response body kept in HTTPError
+)
+
+// HTTPError error of a http request, it's either a transport error (StatusCode is 0 and Cause is not nil)
+// or a non-2xx response from remote server. use errors.As to get it from returned error
+type HTTPError struct {
+	Method     string      //request method
+	URL        string      //request url (password redacted)
+	StatusCode int         //response status code, 0 means no response received
+	Header     http.Header //response header
+	Body       []byte      //response body, truncated to MAX_HTTP_ERROR_BODY_SIZE bytes
+	Cause      error       //underlying error
+}
+
+func (e *HTTPError) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s url [%s] error [%s]", e.Method, e.URL, e.Cause)
+	}
+	return fmt.Sprintf("%s url [%s] remote server status code [%d] body [%s]", e.Method, e.URL, e.StatusCode, e.Body)
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Cause
+}
+
+// WithStrictStatus treat non-2xx http status as *HTTPError for all methods
+func (c *Client) WithStrictStatus(strict bool) *Client {
+	c.locker.Lock()
+	c.strictStatus = strict
+	c.locker.Unlock()
+	return c
+}
+
+// newTransportError make a HTTPError of transport error
+func newTransportError(req *http.Request, err error) *HTTPError {
+	return &HTTPError{
+		Method: req.Method,
+		URL:    redactUrl(req.URL),
+		Cause:  err,
+	}
+}
+
+// newStatusError make a HTTPError of non-2xx response, the response stream will be read and closed if exists
+func newStatusError(strMethod, strUrl string, r *Response) *HTTPError {
+	body := r.Body
+	if r.stream != nil {
+		body, _ = ioutil.ReadAll(io.LimitReader(r.stream, MAX_HTTP_ERROR_BODY_SIZE))
+		_ = r.stream.Close()
+		r.stream = nil
+	}
+	if len(body) > MAX_HTTP_ERROR_BODY_SIZE {
+		body = body[:MAX_HTTP_ERROR_BODY_SIZE]
+	}
+	if u, err := url.Parse(strUrl); err == nil {
+		strUrl = redactUrl(u)
+	}
+	return &HTTPError{
+		Method:     strMethod,
+		URL:        strUrl,
+		StatusCode: r.StatusCode,
+		Header:     r.Header,
+		Body:       body,
+	}
+}
+
+// statusOfError returns the http status code of error, 0 means no response received
+func statusOfError(err error) int {
+	var e *HTTPError
+	if errors.As(err, &e) {
+		return e.StatusCode
+	}
+	return 0
+}
+
+// isSuccessStatus check whether the http status code is 2xx
+func isSuccessStatus(code int) bool {
+	return code >= 200 && code < 300
+}
+
+func redactUrl(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	return u.Redacted()
+}
@@ -3,10 +3,16 @@ module github.com/civet148/httpc
 go 1.15
 
 require (
+	github.com/andybalholm/brotli v1.0.4
 	github.com/civet148/gotools v1.4.1
 	github.com/civet148/log v1.5.1
 	github.com/gin-gonic/gin v1.8.1
 	github.com/gorilla/websocket v1.5.0
+	github.com/klauspost/compress v1.15.15
+	github.com/ugorji/go/codec v1.2.7
 	github.com/urfave/cli/v2 v2.23.7
 	github.com/valyala/fastjson v1.6.4
+	golang.org/x/net v0.0.0-20220225172249-27dd8689420f
+	google.golang.org/protobuf v1.28.0
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,79 @@
+package httpc
+
+import (
+	"context"
+	"github.com/civet148/log"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Handler send the outgoing http request and return the response
+type Handler func(req *http.Request) (*Response, error)
+
+// Middleware wrap the next handler, it could modify the outgoing request before calling next
+// and inspect the response after next returned.
+// NOTE: the response body of SaveFile/CopyFile is streamed to destination so Response.Body is nil
+type Middleware func(next Handler) Handler
+
+type streamContextKey struct{}
+
+// Use append middlewares to the chain around the actual http request of every method, the first one is the outermost
+func (c *Client) Use(middlewares ...Middleware) *Client {
+	c.locker.Lock()
+	c.middlewares = append(c.middlewares, middlewares...)
+	c.locker.Unlock()
+	return c
+}
+
+// execute run the middleware chain with the actual http request at the end
+func (c *Client) execute(req *http.Request) (*Response, error) {
+	c.locker.RLock()
+	middlewares := c.middlewares
+	c.locker.RUnlock()
+
+	var handler Handler = c.roundTrip
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler(req)
+}
+
+// roundTrip send request by http client and read the whole response body unless it's a stream request
+func (c *Client) roundTrip(req *http.Request) (r *Response, err error) {
+	var resp *http.Response
+	var start = time.Now()
+	if resp, err = c.cli.Do(req); err != nil {
+		log.Errorf("send request error [%s]", err)
+		return nil, newTransportError(req, err)
+	}
+	if err = c.decodeResponse(resp); err != nil {
+		return nil, err
+	}
+
+	r = newResponse(resp)
+	if isStreamContext(req.Context()) {
+		r.stream = resp.Body
+		r.Elapsed = time.Since(start)
+		return r, nil
+	}
+
+	defer resp.Body.Close()
+	if r.Body, err = ioutil.ReadAll(resp.Body); err != nil {
+		log.Errorf("%s", err)
+		return
+	}
+	r.Elapsed = time.Since(start)
+	return
+}
+
+// withStreamContext mark the request context as a stream request, the response body will not be read by client
+func withStreamContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamContextKey{}, true)
+}
+
+// isStreamContext check whether the request context is a stream request
+func isStreamContext(ctx context.Context) bool {
+	ok, _ := ctx.Value(streamContextKey{}).(bool)
+	return ok
+}
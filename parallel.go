@@ -0,0 +1,236 @@
+package httpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/civet148/log"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	DEFAULT_DOWNLOAD_SEGMENTS = 4
+	DEFAULT_SEGMENT_MIN_SIZE  = 1 << 20 //the file is not split into segments smaller than 1MiB
+	ACCEPT_RANGES_BYTES       = "bytes"
+)
+
+// segment byte range [start, end] of file
+type segment struct {
+	index int
+	start int64
+	end   int64
+}
+
+// send http requests by GET method and save to file by concurrent range requests, see SaveFileParallelContext
+func (c *Client) SaveFileParallel(strUrl string, strFilePath string, segments int, queries ...url.Values) (size int64, err error) {
+	return c.SaveFileParallelContext(context.Background(), strUrl, strFilePath, segments, queries...)
+}
+
+// send http requests by GET method with context and save to file by concurrent range requests, returns the size of file.
+// the content length and range support are probed by HEAD request first, then the file is preallocated and split into
+// segments (default 4) downloaded concurrently, every failed segment is retried from where it stopped by the retry policy
+// of client (the default policy if client has none), the range requests themselves are sent without client's retry.
+// it falls back to SaveFileContext if the server does not support ranges or the file is too small to split
+func (c *Client) SaveFileParallelContext(ctx context.Context, strUrl string, strFilePath string, segments int, queries ...url.Values) (size int64, err error) {
+	if segments <= 0 {
+		segments = DEFAULT_DOWNLOAD_SEGMENTS
+	}
+	strFullUrl := c.makeQueryUrl(c.resolveUrl(strUrl), queries...)
+	length, validator, header, ok := c.probeRanges(ctx, strFullUrl)
+	if !ok || segments == 1 || length < 2*DEFAULT_SEGMENT_MIN_SIZE {
+		log.Debugf("url [%s] download in single stream", strUrl)
+		return c.saveFile(ctx, strUrl, strFilePath, queries...)
+	}
+	if max := int(length / DEFAULT_SEGMENT_MIN_SIZE); segments > max {
+		segments = max
+	}
+	verifier := c.newVerifier(ctx, header, false, false)
+	strPart := strFilePath + DOWNLOAD_PART_SUFFIX
+	strMeta := strFilePath + DOWNLOAD_META_SUFFIX
+	_ = os.Remove(strMeta) //the segmented partial file can not be resumed by SaveFile
+
+	var f *os.File
+	if f, err = os.OpenFile(strPart, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+		return 0, err
+	}
+	if err = f.Truncate(length); err != nil {
+		_ = f.Close()
+		_ = os.Remove(strPart)
+		return 0, log.Errorf("preallocate file [%s] size [%d] error [%s]", strPart, length, err)
+	}
+	tracker := c.newProgress(ctx, strFullUrl, false, 0, length)
+	err = c.downloadSegments(ctx, strFullUrl, validator, f, splitSegments(length, segments), tracker)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil { //the segments are written out of order, so compute the checksums after all of them done
+		if err = verifier.feedFile(strPart); err == nil {
+			err = verifier.verify()
+		}
+	}
+	if err == nil { //the complete partial file could be "resumed" by SaveFile if rename failed
+		meta := &partMeta{Url: strFullUrl, ETag: header.Get(HEADER_KEY_ETAG), LastModified: header.Get(HEADER_KEY_LAST_MODIFIED)}
+		if e := savePartMeta(strMeta, meta); e != nil {
+			log.Warnf("save partial file meta [%s] error [%s]", strMeta, e)
+		}
+	}
+	if err != nil {
+		_ = os.Remove(strPart)
+		if errors.Is(err, errRangeIgnored) {
+			log.Warnf("url [%s] range ignored by server, download in single stream", strUrl)
+			return c.saveFile(ctx, strUrl, strFilePath, queries...)
+		}
+		return 0, err
+	}
+	tracker.finish()
+	return length, c.finishFile(strPart, strMeta, strFilePath, nil)
+}
+
+// probeRanges send HEAD request to get the content length, validator and response header, ok is false if the range is not supported
+func (c *Client) probeRanges(ctx context.Context, strUrl string) (length int64, validator string, header http.Header, ok bool) {
+	r, err := c.SendRequestContext(ctx, c.downloadHeader(), HTTP_METHOD_HEAD, strUrl, nil)
+	if err != nil {
+		log.Warnf("probe url [%s] error [%s]", strUrl, err)
+		return 0, "", nil, false
+	}
+	if r.StatusCode != http.StatusOK || !strings.EqualFold(r.Header.Get(HEADER_KEY_ACCEPT_RANGES), ACCEPT_RANGES_BYTES) {
+		return 0, "", nil, false
+	}
+	if r.Header.Get(HEADER_KEY_CONTENT_ENCODING) != "" {
+		return 0, "", nil, false
+	}
+	if length, err = strconv.ParseInt(r.Header.Get(HEADER_KEY_CONTENT_LENGTH), 10, 64); err != nil || length <= 0 {
+		return 0, "", nil, false
+	}
+	meta := &partMeta{ETag: r.ETag(), LastModified: r.Header.Get(HEADER_KEY_LAST_MODIFIED)}
+	return length, meta.validator(), r.Header, true
+}
+
+// downloadSegments download all segments concurrently, the others are canceled once a segment failed
+func (c *Client) downloadSegments(ctx context.Context, strUrl, validator string, f *os.File, segments []*segment, tracker *progressTracker) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var retry = c.segmentRetry()
+	for _, seg := range segments {
+		wg.Add(1)
+		go func(seg *segment) {
+			defer wg.Done()
+			if e := c.downloadSegment(ctx, strUrl, validator, f, seg, retry, tracker); e != nil {
+				once.Do(func() {
+					err = e
+					cancel()
+				})
+			}
+		}(seg)
+	}
+	wg.Wait()
+	return err
+}
+
+// segmentRetry returns the retry policy of segments, it's the client's policy or the default one if client has none
+func (c *Client) segmentRetry() *RetryPolicy {
+	c.locker.RLock()
+	retry := c.retry
+	c.locker.RUnlock()
+	if retry == nil {
+		return DefaultRetryPolicy()
+	}
+	return retry
+}
+
+// downloadSegment download a segment and retry from the last written offset if failed
+func (c *Client) downloadSegment(ctx context.Context, strUrl, validator string, f *os.File, seg *segment, retry *RetryPolicy, tracker *progressTracker) (err error) {
+	var offset = seg.start
+	for attempt := 1; ; attempt++ {
+		var written int64
+		written, err = c.fetchRange(ctx, strUrl, validator, f, offset, seg.end, tracker)
+		offset += written
+		if err == nil {
+			return nil
+		}
+		var r *Response
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.StatusCode != 0 {
+			r = &Response{StatusCode: httpErr.StatusCode, Header: httpErr.Header}
+		}
+		if errors.Is(err, errRangeIgnored) || attempt >= retry.MaxAttempts || !retry.shouldRetry(ctx, r, err) {
+			log.Errorf("download segment [%d] bytes [%d-%d] error [%s]", seg.index, seg.start, seg.end, err)
+			return err
+		}
+		wait := retry.backoff(attempt, r)
+		log.Warnf("download segment [%d] bytes [%d-%d] attempt [%d/%d] failed at offset [%d], retry after [%v]",
+			seg.index, seg.start, seg.end, attempt, retry.MaxAttempts, offset, wait)
+		if err = sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// fetchRange download bytes [start, end] of url and write them at the same offset of file, the request is sent once
+// since the segment is retried by downloadSegment
+func (c *Client) fetchRange(ctx context.Context, strUrl, validator string, f *os.File, start, end int64, tracker *progressTracker) (written int64, err error) {
+	header := c.downloadHeader()
+	header.Set(HEADER_KEY_RANGE, fmt.Sprintf("bytes=%d-%d", start, end))
+	if validator != "" {
+		header.Set(HEADER_KEY_IF_RANGE, validator)
+	}
+	var r *Response
+	if r, err = c.sendOnce(withStreamContext(ctx), header, HTTP_METHOD_GET, strUrl, nil); err != nil {
+		return 0, err
+	}
+	defer r.stream.Close()
+	if r.StatusCode != http.StatusPartialContent {
+		if isSuccessStatus(r.StatusCode) { //range not supported or the content has been changed
+			return 0, errRangeIgnored
+		}
+		return 0, newStatusError(HTTP_METHOD_GET, strUrl, r)
+	}
+	if offset, _, ok := parseContentRange(r.Header.Get(HEADER_KEY_CONTENT_RANGE)); !ok || offset != start {
+		return 0, errRangeIgnored
+	}
+	if written, err = io.Copy(&offsetWriter{f: f, offset: start}, tracker.reader(io.LimitReader(r.stream, end-start+1), false)); err != nil {
+		return written, err
+	}
+	if written < end-start+1 {
+		return written, io.ErrUnexpectedEOF
+	}
+	return written, nil
+}
+
+// splitSegments split length into n segments of almost the same size
+func splitSegments(length int64, n int) (segments []*segment) {
+	size := length / int64(n)
+	for i := 0; i < n; i++ {
+		seg := &segment{
+			index: i,
+			start: int64(i) * size,
+			end:   int64(i+1)*size - 1,
+		}
+		if i == n-1 {
+			seg.end = length - 1
+		}
+		segments = append(segments, seg)
+	}
+	return
+}
+
+// offsetWriter write to file sequentially from offset by WriteAt, it's safe to write different ranges of file concurrently
+type offsetWriter struct {
+	f      *os.File
+	offset int64
+}
+
+func (w *offsetWriter) Write(p []byte) (n int, err error) {
+	n, err = w.f.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	return
+}
@@ -0,0 +1,160 @@
+package httpc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitSegments(t *testing.T) {
+	var cases = []struct {
+		length int64
+		n      int
+		expect [][2]int64
+	}{
+		{10, 1, [][2]int64{{0, 9}}},
+		{10, 2, [][2]int64{{0, 4}, {5, 9}}},
+		{10, 3, [][2]int64{{0, 2}, {3, 5}, {6, 9}}},
+		{8, 4, [][2]int64{{0, 1}, {2, 3}, {4, 5}, {6, 7}}},
+	}
+	for _, c := range cases {
+		segments := splitSegments(c.length, c.n)
+		if len(segments) != len(c.expect) {
+			t.Errorf("splitSegments(%d, %d) returns [%d] segments, expect [%d]", c.length, c.n, len(segments), len(c.expect))
+			continue
+		}
+		for i, seg := range segments {
+			if seg.index != i || seg.start != c.expect[i][0] || seg.end != c.expect[i][1] {
+				t.Errorf("splitSegments(%d, %d) segment [%d] = %+v, expect %v", c.length, c.n, i, *seg, c.expect[i])
+			}
+		}
+	}
+}
+
+func TestSaveFileParallel(t *testing.T) {
+	content := make([]byte, 3*DEFAULT_SEGMENT_MIN_SIZE+12345)
+	rand.New(rand.NewSource(1)).Read(content)
+	srv := &rangeServer{etag: `"v1"`, content: content}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	strDir, err := ioutil.TempDir("", "httpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(strDir)
+	strFile := filepath.Join(strDir, "data.bin")
+
+	size, err := NewClient().SaveFileParallel(ts.URL+"/data.bin", strFile, 8)
+	if err != nil {
+		t.Fatalf("save file parallel error [%s]", err)
+	}
+	if size != int64(len(content)) {
+		t.Errorf("save file parallel size [%d], expect [%d]", size, len(content))
+	}
+	if data, e := ioutil.ReadFile(strFile); e != nil || !bytes.Equal(data, content) {
+		t.Errorf("saved file [%d bytes] error [%v] mismatched content", len(data), e)
+	}
+	var segments int
+	for _, strRange := range srv.ranges {
+		if strings.HasPrefix(strRange, "bytes=") {
+			segments++
+		}
+	}
+	if segments != 3 { //segments are limited to length/DEFAULT_SEGMENT_MIN_SIZE
+		t.Errorf("range requests %q, expect 3 segments", srv.ranges)
+	}
+	if _, e := os.Stat(strFile + DOWNLOAD_PART_SUFFIX); e == nil {
+		t.Errorf("partial file is not removed")
+	}
+}
+
+func TestSaveFileParallelFallback(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 100))
+	srv := &rangeServer{etag: `"v1"`, content: content}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	strDir, err := ioutil.TempDir("", "httpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(strDir)
+	strFile := filepath.Join(strDir, "data.bin")
+
+	if _, err = NewClient().SaveFileParallel(ts.URL+"/data.bin", strFile, 4); err != nil {
+		t.Fatalf("save small file parallel error [%s]", err)
+	}
+	if data, e := ioutil.ReadFile(strFile); e != nil || !bytes.Equal(data, content) {
+		t.Errorf("saved file [%d bytes] error [%v] mismatched content", len(data), e)
+	}
+	for _, strRange := range srv.ranges {
+		if strRange != "" {
+			t.Errorf("range requests %q, expect single stream for small file", srv.ranges)
+			break
+		}
+	}
+}
+
+// flakyRangeServer fail the first failures range requests of every segment start offset with 503
+type flakyRangeServer struct {
+	rangeServer
+	failures int
+	attempts map[string]int
+}
+
+func (s *flakyRangeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strRange := r.Header.Get(HEADER_KEY_RANGE); strRange != "" {
+		s.locker.Lock()
+		s.attempts[strRange]++
+		n := s.attempts[strRange]
+		s.locker.Unlock()
+		if n <= s.failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}
+	s.rangeServer.ServeHTTP(w, r)
+}
+
+func TestSaveFileParallelRetry(t *testing.T) {
+	content := make([]byte, 2*DEFAULT_SEGMENT_MIN_SIZE)
+	rand.New(rand.NewSource(2)).Read(content)
+	var cases = []struct {
+		name     string
+		failures int
+		ok       bool
+	}{
+		{"recovered", 2, true},
+		{"exhausted", 100, false},
+	}
+	for _, c := range cases {
+		srv := &flakyRangeServer{rangeServer: rangeServer{etag: `"v1"`, content: content}, failures: c.failures, attempts: make(map[string]int)}
+		ts := httptest.NewServer(srv)
+		strDir, err := ioutil.TempDir("", "httpc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		strFile := filepath.Join(strDir, "data.bin")
+		cli := NewClient().WithRetry(&RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+
+		_, err = cli.SaveFileParallel(ts.URL, strFile, 2)
+		if c.ok != (err == nil) {
+			t.Errorf("%s: save file parallel error [%v]", c.name, err)
+		}
+		srv.locker.Lock()
+		for strRange, n := range srv.attempts {
+			if n > 3 { //the range requests must not be retried by client again
+				t.Errorf("%s: range [%s] requested [%d] times, expect at most 3", c.name, strRange, n)
+			}
+		}
+		srv.locker.Unlock()
+		ts.Close()
+		_ = os.RemoveAll(strDir)
+	}
+}
@@ -0,0 +1,161 @@
+package httpc
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	PROGRESS_REPORT_INTERVAL = 200 * time.Millisecond //min interval between two progress reports
+)
+
+// Progress transfer progress of a download or upload
+type Progress struct {
+	Url         string        //request url
+	Upload      bool          //true is uploading request body, false is downloading response body
+	Transferred int64         //bytes transferred, including the bytes of partial file resumed
+	Total       int64         //total bytes, -1 means unknown
+	Rate        float64       //average bytes per second of this transfer
+	Elapsed     time.Duration //time elapsed since the transfer started
+	ETA         time.Duration //estimated time to complete, -1 means unknown
+	Done        bool          //the transfer completed, it's the last report
+}
+
+// ProgressFunc called at most every 200ms during transfer and once when completed
+type ProgressFunc func(p *Progress)
+
+type progressContextKey struct{}
+
+// ContextWithProgress returns a context which reports the progress of request by fn, it overrides client's progress function
+func ContextWithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressContextKey{}, fn)
+}
+
+// WithProgress set progress function of client for SaveFile/CopyFile downloads and request body uploads, nil means disabled
+func (c *Client) WithProgress(fn ProgressFunc) *Client {
+	c.locker.Lock()
+	c.progress = fn
+	c.locker.Unlock()
+	return c
+}
+
+// progressTracker count the bytes transferred and report progress, it's safe for concurrent use
+type progressTracker struct {
+	fn          ProgressFunc
+	url         string
+	upload      bool
+	total       int64
+	initial     int64
+	transferred int64
+	start       time.Time
+	locker      sync.Mutex
+	last        time.Time
+	done        bool
+}
+
+// newProgress make a progress tracker by the progress function of context or client, nil if progress disabled.
+// initial is the bytes already transferred (e.g. size of resumed partial file) and total is -1 if unknown
+func (c *Client) newProgress(ctx context.Context, strUrl string, upload bool, initial, total int64) *progressTracker {
+	fn, _ := ctx.Value(progressContextKey{}).(ProgressFunc)
+	if fn == nil {
+		c.locker.RLock()
+		fn = c.progress
+		c.locker.RUnlock()
+	}
+	if fn == nil {
+		return nil
+	}
+	return &progressTracker{
+		fn:          fn,
+		url:         strUrl,
+		upload:      upload,
+		total:       total,
+		initial:     initial,
+		transferred: initial,
+		start:       time.Now(),
+	}
+}
+
+// reader count the bytes read from r, the progress is finished on EOF if finishOnEOF is true
+func (t *progressTracker) reader(r io.Reader, finishOnEOF bool) io.Reader {
+	if t == nil {
+		return r
+	}
+	return &progressReader{reader: r, tracker: t, finishOnEOF: finishOnEOF}
+}
+
+func (t *progressTracker) add(n int64) {
+	if t == nil || n <= 0 {
+		return
+	}
+	atomic.AddInt64(&t.transferred, n)
+	t.report(false)
+}
+
+// finish report the last progress
+func (t *progressTracker) finish() {
+	if t == nil {
+		return
+	}
+	t.report(true)
+}
+
+func (t *progressTracker) report(done bool) {
+	now := time.Now()
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	if t.done || (!done && now.Sub(t.last) < PROGRESS_REPORT_INTERVAL) {
+		return
+	}
+	t.last = now
+	t.done = done
+	p := &Progress{
+		Url:         t.url,
+		Upload:      t.upload,
+		Transferred: atomic.LoadInt64(&t.transferred),
+		Total:       t.total,
+		Elapsed:     now.Sub(t.start),
+		ETA:         -1,
+		Done:        done,
+	}
+	if done && p.Total < 0 {
+		p.Total = p.Transferred
+	}
+	if seconds := p.Elapsed.Seconds(); seconds > 0 {
+		p.Rate = float64(p.Transferred-t.initial) / seconds
+	}
+	if done {
+		p.ETA = 0
+	} else if p.Total >= 0 && p.Rate > 0 {
+		p.ETA = time.Duration(float64(p.Total-p.Transferred) / p.Rate * float64(time.Second))
+	}
+	t.fn(p)
+}
+
+type progressReader struct {
+	reader      io.Reader
+	tracker     *progressTracker
+	finishOnEOF bool
+}
+
+func (r *progressReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	r.tracker.add(int64(n))
+	if err == io.EOF && r.finishOnEOF {
+		r.tracker.finish()
+	}
+	return
+}
+
+// progressReadCloser count the bytes of request body and close the underlying body
+type progressReadCloser struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (r *progressReadCloser) Close() error {
+	return r.closer.Close()
+}
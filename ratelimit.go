@@ -0,0 +1,220 @@
+package httpc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RATE_LIMIT_SWEEP_INTERVAL = time.Minute //the idle host buckets which are full are removed at most once per interval
+)
+
+// RateLimit token bucket limit, Rate tokens are added per second and at most Burst tokens are kept
+type RateLimit struct {
+	Rate  float64 //requests per second, zero or negative means no limit
+	Burst int     //max requests sent at once (default ceil(Rate), at least 1)
+}
+
+// RateLimitPolicy client side rate limit, a request must take a token from both the global bucket and its host's bucket
+type RateLimitPolicy struct {
+	Global   *RateLimit            //limit of all requests of client
+	PerHost  *RateLimit            //default limit of every host not in Hosts
+	Hosts    map[string]*RateLimit //limit of specified host, the key is "host:port" or "host"
+	FailFast bool                  //return *RateLimitError immediately instead of waiting for a token
+}
+
+// RateLimitError returned when no token available in fail-fast mode
+type RateLimitError struct {
+	Host       string        //host of the request, empty means the global limit reached
+	RetryAfter time.Duration //time to wait for the next token
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Host == "" {
+		return fmt.Sprintf("global rate limit exceeded, retry after [%v]", e.RetryAfter)
+	}
+	return fmt.Sprintf("rate limit of host [%s] exceeded, retry after [%v]", e.Host, e.RetryAfter)
+}
+
+type rateLimiter struct {
+	policy    RateLimitPolicy
+	global    *tokenBucket
+	locker    sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	locker sync.Mutex
+}
+
+// WithRateLimit set rate limit policy of client, nil means no limit
+func (c *Client) WithRateLimit(policy *RateLimitPolicy) *Client {
+	c.locker.Lock()
+	c.limiter = newRateLimiter(policy)
+	c.locker.Unlock()
+	return c
+}
+
+// waitRateLimit take a token for the request host, wait until it's available or ctx is done
+func (c *Client) waitRateLimit(ctx context.Context, strHost string) error {
+	c.locker.RLock()
+	limiter := c.limiter
+	c.locker.RUnlock()
+	if limiter == nil {
+		return nil
+	}
+	return limiter.wait(ctx, strHost)
+}
+
+func newRateLimiter(policy *RateLimitPolicy) *rateLimiter {
+	if policy == nil {
+		return nil
+	}
+	var hosts = make(map[string]*RateLimit)
+	for k, v := range policy.Hosts {
+		hosts[strings.ToLower(k)] = v
+	}
+	l := &rateLimiter{
+		policy:  *policy,
+		global:  newTokenBucket(policy.Global),
+		buckets: make(map[string]*tokenBucket),
+	}
+	l.policy.Hosts = hosts
+	return l
+}
+
+func (l *rateLimiter) wait(ctx context.Context, strHost string) error {
+	var now = time.Now()
+	var bucket = l.bucketOfHost(strHost)
+	var reserved []*tokenBucket
+	var wait time.Duration
+	var limitHost string
+	for _, b := range []*tokenBucket{l.global, bucket} {
+		if b == nil {
+			continue
+		}
+		reserved = append(reserved, b)
+		if d := b.reserve(now); d > wait {
+			wait = d
+			if b == bucket {
+				limitHost = strHost
+			} else {
+				limitHost = ""
+			}
+		}
+	}
+	if wait <= 0 {
+		return nil
+	}
+	if l.policy.FailFast {
+		cancelReserved(reserved)
+		return &RateLimitError{Host: limitHost, RetryAfter: wait}
+	}
+	if err := sleepContext(ctx, wait); err != nil {
+		cancelReserved(reserved)
+		return err
+	}
+	return nil
+}
+
+// bucketOfHost returns the token bucket of host, nil means no limit
+func (l *rateLimiter) bucketOfHost(strHost string) *tokenBucket {
+	strKey := strings.ToLower(strHost)
+	l.locker.Lock()
+	defer l.locker.Unlock()
+	now := time.Now()
+	if now.Sub(l.lastSweep) >= RATE_LIMIT_SWEEP_INTERVAL {
+		l.lastSweep = now
+		l.sweep(now)
+	}
+	if b, ok := l.buckets[strKey]; ok {
+		return b
+	}
+	limit := l.policy.PerHost
+	if v, ok := l.policy.Hosts[strKey]; ok {
+		limit = v
+	} else if host, _, err := net.SplitHostPort(strKey); err == nil {
+		if v, ok = l.policy.Hosts[host]; ok {
+			limit = v
+		}
+	}
+	b := newTokenBucket(limit)
+	if b != nil { //the hosts without limit are not kept
+		l.buckets[strKey] = b
+	}
+	return b
+}
+
+// sweep remove the buckets which are full and idle for a sweep interval, they are the same as new buckets.
+// the caller must hold the lock
+func (l *rateLimiter) sweep(now time.Time) {
+	for k, b := range l.buckets {
+		if b.idle(now, RATE_LIMIT_SWEEP_INTERVAL) {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+func newTokenBucket(limit *RateLimit) *tokenBucket {
+	if limit == nil || limit.Rate <= 0 {
+		return nil
+	}
+	burst := float64(limit.Burst)
+	if burst <= 0 {
+		burst = math.Max(1, math.Ceil(limit.Rate))
+	}
+	return &tokenBucket{
+		rate:   limit.Rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// reserve take a token from bucket and returns the duration to wait until it's available
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	if now.After(b.last) {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// idle check whether the bucket is full and not used for the duration
+func (b *tokenBucket) idle(now time.Time, d time.Duration) bool {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	if now.Sub(b.last) < d {
+		return false
+	}
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
+
+// cancel put back a reserved token
+func (b *tokenBucket) cancel() {
+	b.locker.Lock()
+	b.tokens = math.Min(b.burst, b.tokens+1)
+	b.locker.Unlock()
+}
+
+func cancelReserved(buckets []*tokenBucket) {
+	for _, b := range buckets {
+		b.cancel()
+	}
+}
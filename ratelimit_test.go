@@ -0,0 +1,117 @@
+package httpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketReserve(t *testing.T) {
+	var now = time.Now()
+	var cases = []struct {
+		name    string
+		limit   RateLimit
+		elapsed []time.Duration //elapsed time from start of every reserve
+		expect  []time.Duration //wait duration of every reserve
+	}{
+		{"burst", RateLimit{Rate: 10, Burst: 3}, []time.Duration{0, 0, 0, 0}, []time.Duration{0, 0, 0, 100 * time.Millisecond}},
+		{"queued", RateLimit{Rate: 10, Burst: 1}, []time.Duration{0, 0, 0}, []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond}},
+		{"refill", RateLimit{Rate: 10, Burst: 1}, []time.Duration{0, 100 * time.Millisecond, 150 * time.Millisecond}, []time.Duration{0, 0, 50 * time.Millisecond}},
+		{"capped", RateLimit{Rate: 10, Burst: 2}, []time.Duration{time.Hour, time.Hour, time.Hour}, []time.Duration{0, 0, 100 * time.Millisecond}},
+		{"default burst", RateLimit{Rate: 2.5}, []time.Duration{0, 0, 0, 0}, []time.Duration{0, 0, 0, 400 * time.Millisecond}},
+	}
+	for _, c := range cases {
+		b := newTokenBucket(&c.limit)
+		b.last = now
+		for i, elapsed := range c.elapsed {
+			if d := b.reserve(now.Add(elapsed)); !durationNear(d, c.expect[i]) {
+				t.Errorf("%s: reserve [%d] wait [%v], expect [%v]", c.name, i, d, c.expect[i])
+			}
+		}
+	}
+}
+
+func TestTokenBucketNoLimit(t *testing.T) {
+	for _, limit := range []*RateLimit{nil, {Rate: 0, Burst: 10}, {Rate: -1}} {
+		if b := newTokenBucket(limit); b != nil {
+			t.Errorf("newTokenBucket(%+v) = %+v, expect nil", limit, b)
+		}
+	}
+}
+
+func TestRateLimiterFailFast(t *testing.T) {
+	l := newRateLimiter(&RateLimitPolicy{
+		PerHost:  &RateLimit{Rate: 1, Burst: 1},
+		Hosts:    map[string]*RateLimit{"Slow.example.com": {Rate: 0.1, Burst: 1}},
+		FailFast: true,
+	})
+	ctx := context.Background()
+	if err := l.wait(ctx, "a.example.com:80"); err != nil {
+		t.Fatalf("first request error [%s]", err)
+	}
+	var limitErr *RateLimitError
+	if err := l.wait(ctx, "a.example.com:80"); !errors.As(err, &limitErr) || limitErr.Host != "a.example.com:80" {
+		t.Fatalf("second request error [%v], expect rate limit of host", err)
+	}
+	if err := l.wait(ctx, "b.example.com:80"); err != nil {
+		t.Fatalf("other host error [%s]", err)
+	}
+	if err := l.wait(ctx, "slow.example.com:443"); err != nil {
+		t.Fatalf("first request of slow host error [%s]", err)
+	}
+	if err := l.wait(ctx, "slow.example.com:443"); !errors.As(err, &limitErr) || limitErr.RetryAfter < 9*time.Second {
+		t.Fatalf("second request of slow host error [%v], expect retry after about 10s", err)
+	}
+}
+
+func TestRateLimiterUnlimitedHost(t *testing.T) {
+	l := newRateLimiter(&RateLimitPolicy{
+		Hosts:    map[string]*RateLimit{"a.example.com": {Rate: 1}},
+		FailFast: true,
+	})
+	for i := 0; i < 10; i++ {
+		if err := l.wait(context.Background(), "b.example.com:80"); err != nil {
+			t.Fatalf("unlimited host error [%s]", err)
+		}
+	}
+	if len(l.buckets) != 0 {
+		t.Errorf("unlimited host kept [%d] buckets, expect none", len(l.buckets))
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	l := newRateLimiter(&RateLimitPolicy{PerHost: &RateLimit{Rate: 10, Burst: 1}})
+	if l.bucketOfHost("a.example.com:80") == nil || l.bucketOfHost("b.example.com:80") == nil {
+		t.Fatalf("bucket of limited host is nil")
+	}
+	now := time.Now().Add(RATE_LIMIT_SWEEP_INTERVAL)
+	l.buckets["b.example.com:80"].reserve(now)
+	l.sweep(now.Add(time.Millisecond))
+	if _, ok := l.buckets["a.example.com:80"]; ok {
+		t.Errorf("idle full bucket is not removed")
+	}
+	if _, ok := l.buckets["b.example.com:80"]; !ok {
+		t.Errorf("recently used bucket is removed")
+	}
+}
+
+func TestRateLimiterWaitCanceled(t *testing.T) {
+	l := newRateLimiter(&RateLimitPolicy{Global: &RateLimit{Rate: 0.1, Burst: 1}})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.wait(ctx, "a.example.com:80"); err != nil {
+		t.Fatalf("first request error [%s]", err)
+	}
+	if err := l.wait(ctx, "a.example.com:80"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled request error [%v], expect context canceled", err)
+	}
+	if d := l.global.reserve(time.Now()); d < 9*time.Second || d > 10*time.Second { //the token of canceled request is given back
+		t.Errorf("wait after canceled request [%v], expect about 10s", d)
+	}
+}
+
+func durationNear(d, expect time.Duration) bool {
+	diff := d - expect
+	return diff > -time.Millisecond && diff < time.Millisecond
+}
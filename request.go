@@ -0,0 +1,205 @@
+package httpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/civet148/log"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Request is a per-request builder which carries its own headers, query, body and content type.
+// the headers of request will be merged over client's default headers without touching the shared state.
+type Request struct {
+	client     *Client
+	ctx        context.Context
+	header     http.Header
+	query      url.Values
+	body       interface{}
+	pathParams map[string]string
+}
+
+// R create a new request builder of client
+func (c *Client) R() *Request {
+	return &Request{
+		client:     c,
+		ctx:        context.Background(),
+		header:     http.Header{},
+		query:      url.Values{},
+		pathParams: map[string]string{},
+	}
+}
+
+// SetContext set request context for cancellation and deadline
+func (r *Request) SetContext(ctx context.Context) *Request {
+	if ctx != nil {
+		r.ctx = ctx
+	}
+	return r
+}
+
+// SetHeader set request header key and value (override client's default header)
+func (r *Request) SetHeader(key, value string) *Request {
+	r.header.Set(key, value)
+	return r
+}
+
+// SetHeaders set request headers from a map of key=value
+func (r *Request) SetHeaders(headers map[string]string) *Request {
+	for k, v := range headers {
+		r.header.Set(k, v)
+	}
+	return r
+}
+
+// SetContentType set request header Content-Type
+func (r *Request) SetContentType(contentType string) *Request {
+	return r.SetHeader(HEADER_KEY_CONTENT_TYPE, contentType)
+}
+
+// SetToken set request header token
+func (r *Request) SetToken(token string) *Request {
+	return r.SetHeader(HEADER_KEY_TOKEN, token)
+}
+
+// SetBasicAuth set request header Authorization with basic auth
+func (r *Request) SetBasicAuth(username, password string) *Request {
+	return r.SetHeader(HEADER_KEY_AUTHORIZATION, "Basic "+basicAuth(username, password))
+}
+
+// SetBearerToken set request header Authorization with bearer token
+func (r *Request) SetBearerToken(token string) *Request {
+	return r.SetHeader(HEADER_KEY_AUTHORIZATION, "Bearer "+token)
+}
+
+// SetQuery add a URL query parameter, value will be formatted by %v
+func (r *Request) SetQuery(key string, value interface{}) *Request {
+	r.query.Add(key, fmt.Sprintf("%v", value))
+	return r
+}
+
+// SetQueryValues add URL query parameters
+func (r *Request) SetQueryValues(queries ...url.Values) *Request {
+	for _, query := range queries {
+		for k, vs := range query {
+			for _, v := range vs {
+				r.query.Add(k, v)
+			}
+		}
+	}
+	return r
+}
+
+// SetBody set request body, data type could be string,[]byte,url.Values,io.Reader,struct and so on
+func (r *Request) SetBody(data interface{}) *Request {
+	r.body = data
+	return r
+}
+
+// Get send request by GET method
+func (r *Request) Get(strUrl string) (*Response, error) {
+	return r.Send(HTTP_METHOD_GET, strUrl)
+}
+
+// Post send request by POST method
+func (r *Request) Post(strUrl string) (*Response, error) {
+	return r.Send(HTTP_METHOD_POST, strUrl)
+}
+
+// Put send request by PUT method
+func (r *Request) Put(strUrl string) (*Response, error) {
+	return r.Send(HTTP_METHOD_PUT, strUrl)
+}
+
+// Delete send request by DELETE method
+func (r *Request) Delete(strUrl string) (*Response, error) {
+	return r.Send(HTTP_METHOD_DELETE, strUrl)
+}
+
+// Patch send request by PATCH method
+func (r *Request) Patch(strUrl string) (*Response, error) {
+	return r.Send(HTTP_METHOD_PATCH, strUrl)
+}
+
+// Head send request by HEAD method
+func (r *Request) Head(strUrl string) (*Response, error) {
+	return r.Send(HTTP_METHOD_HEAD, strUrl)
+}
+
+// Options send request by OPTIONS method
+func (r *Request) Options(strUrl string) (*Response, error) {
+	return r.Send(HTTP_METHOD_OPTIONS, strUrl)
+}
+
+// Trace send request by TRACE method
+func (r *Request) Trace(strUrl string) (*Response, error) {
+	return r.Send(HTTP_METHOD_TRACE, strUrl)
+}
+
+// Send send request by method specified
+func (r *Request) Send(strMethod, strUrl string) (resp *Response, err error) {
+	var header http.Header
+	var body io.Reader
+	if header, body, err = r.build(); err != nil {
+		return nil, err
+	}
+	return r.client.SendRequestContext(r.ctx, header, strMethod, MakePathUrl(strUrl, r.pathParams), body, r.queries()...)
+}
+
+// build merge request headers over client's default headers and encode body by the content type
+func (r *Request) build() (header http.Header, body io.Reader, err error) {
+	header = r.client.mergeHeader(r.header)
+	if body, err = makeBody(header.Get(HEADER_KEY_CONTENT_TYPE), r.body); err != nil {
+		return nil, nil, err
+	}
+	return header, body, nil
+}
+
+func (r *Request) queries() []url.Values {
+	if len(r.query) == 0 {
+		return nil
+	}
+	return []url.Values{r.query}
+}
+
+// mergeHeader clone client's default headers and merge request headers over it
+func (c *Client) mergeHeader(header http.Header) http.Header {
+	c.locker.RLock()
+	merged := c.header.Clone()
+	c.locker.RUnlock()
+	if merged == nil {
+		merged = http.Header{}
+	}
+	for k, vs := range header {
+		merged[k] = append([]string(nil), vs...)
+	}
+	return merged
+}
+
+// makeBody make request body reader from data, the data which is not string,[]byte,url.Values or io.Reader
+// will be encoded by the codec of content type (json by default)
+func makeBody(contentType string, data interface{}) (body io.Reader, err error) {
+	if data == nil {
+		return nil, nil
+	}
+	switch v := data.(type) { //请求体body
+	case url.Values:
+		body = strings.NewReader(v.Encode())
+	case string:
+		body = strings.NewReader(v)
+	case []byte:
+		body = bytes.NewReader(v)
+	case io.Reader:
+		body = v
+	default:
+		var encoded []byte
+		if encoded, err = getCodecOrJson(contentType).Marshal(data); err != nil {
+			return nil, log.Errorf("can't marshal data by content type [%s], error [%v]", contentType, err.Error())
+		}
+		body = bytes.NewReader(encoded)
+	}
+	return body, nil
+}
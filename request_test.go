@@ -0,0 +1,41 @@
+package httpc
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestClientHeader(t *testing.T) {
+	header := http.Header{"Token": {"option"}}
+	c := NewClient(&Option{Header: header})
+	c.SetHeader("Token", "client")
+	if header.Get("Token") != "option" {
+		t.Errorf("header of option is modified to [%s]", header.Get("Token"))
+	}
+	if NewClient().GetHeader() == nil {
+		t.Errorf("header of client without option is nil")
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("Token")))
+	}))
+	defer ts.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			c.SetHeader(fmt.Sprintf("X-Header-%d", i), "value")
+		}(i)
+		go func() {
+			defer wg.Done()
+			if r, err := c.R().SetHeader("X-Request", "request").Get(ts.URL); err != nil || string(r.Body) != "client" {
+				t.Errorf("request with client header error [%v]", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
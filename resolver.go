@@ -0,0 +1,123 @@
+package httpc
+
+import (
+	"context"
+	"github.com/civet148/log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_DNS_CACHE_TTL = 60 * time.Second
+)
+
+// Resolver lookup the addresses of host, *net.Resolver implements it
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) (addrs []string, err error)
+}
+
+// CachedResolver cache the addresses looked up by underlying resolver for TTL
+type CachedResolver struct {
+	resolver Resolver
+	ttl      time.Duration
+	locker   sync.RWMutex
+	cache    map[string]*dnsEntry
+}
+
+type dnsEntry struct {
+	addrs  []string
+	expire time.Time
+}
+
+// NewCachedResolver make a resolver caches results of resolver (default net.DefaultResolver) for ttl (default 60s)
+func NewCachedResolver(resolver Resolver, ttl time.Duration) *CachedResolver {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	if ttl <= 0 {
+		ttl = DEFAULT_DNS_CACHE_TTL
+	}
+	return &CachedResolver{
+		resolver: resolver,
+		ttl:      ttl,
+		cache:    make(map[string]*dnsEntry),
+	}
+}
+
+// LookupHost returns cached addresses of host or lookup by the underlying resolver when expired
+func (r *CachedResolver) LookupHost(ctx context.Context, host string) (addrs []string, err error) {
+	r.locker.RLock()
+	entry, ok := r.cache[host]
+	r.locker.RUnlock()
+	if ok && time.Now().Before(entry.expire) {
+		return entry.addrs, nil
+	}
+	if addrs, err = r.resolver.LookupHost(ctx, host); err != nil {
+		return nil, err
+	}
+	r.locker.Lock()
+	r.cache[host] = &dnsEntry{
+		addrs:  addrs,
+		expire: time.Now().Add(r.ttl),
+	}
+	r.locker.Unlock()
+	return addrs, nil
+}
+
+// Flush remove all cached addresses
+func (r *CachedResolver) Flush() {
+	r.locker.Lock()
+	r.cache = make(map[string]*dnsEntry)
+	r.locker.Unlock()
+}
+
+// withResolve dial the addresses of static host overrides or custom resolver instead of the host name,
+// the TLS server name and Host header are still the original host name since only the dial address changes
+func withResolve(opt *Option, next DialFunc) DialFunc {
+	if len(opt.Resolve) == 0 && opt.Resolver == nil {
+		return next
+	}
+	var overrides = make(map[string]string)
+	for k, v := range opt.Resolve {
+		overrides[strings.ToLower(k)] = v
+	}
+	resolver := opt.Resolver
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return next(ctx, network, addr)
+		}
+		var addrs []string
+		strKey := strings.ToLower(addr)
+		strHost := strings.ToLower(host)
+		if v, ok := overrides[strKey]; ok {
+			addrs = strings.Split(v, ",")
+		} else if v, ok = overrides[strHost]; ok {
+			addrs = strings.Split(v, ",")
+		} else if resolver != nil {
+			if addrs, err = resolver.LookupHost(ctx, host); err != nil {
+				return nil, log.Errorf("resolve host [%s] error [%s]", host, err)
+			}
+		}
+		if len(addrs) == 0 {
+			return next(ctx, network, addr)
+		}
+		var conn net.Conn
+		for _, strAddr := range addrs {
+			strAddr = strings.TrimSpace(strAddr)
+			if _, _, e := net.SplitHostPort(strAddr); e != nil {
+				strAddr = net.JoinHostPort(strings.Trim(strAddr, "[]"), port)
+			}
+			if conn, err = next(ctx, network, strAddr); err == nil {
+				log.Debugf("dial [%s] resolved to [%s]", addr, strAddr)
+				return conn, nil
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, err
+	}
+}
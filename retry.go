@@ -0,0 +1,189 @@
+package httpc
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	DEFAULT_RETRY_MAX_ATTEMPTS = 3
+	DEFAULT_RETRY_MIN_BACKOFF  = 100 * time.Millisecond
+	DEFAULT_RETRY_MAX_BACKOFF  = 10 * time.Second
+)
+
+const (
+	HEADER_KEY_RETRY_AFTER = "Retry-After"
+)
+
+// RetryCondition decide whether a request should be sent again by response and error of last attempt
+type RetryCondition func(r *Response, err error) bool
+
+// RetryPolicy retry policy of client, zero value fields will be set to default
+type RetryPolicy struct {
+	MaxAttempts        int            //max attempts include the first one (default 3)
+	MinBackoff         time.Duration  //backoff of the first retry, doubled for every next retry (default 100ms)
+	MaxBackoff         time.Duration  //max backoff of retry, Retry-After header value is capped to it too (default 10s)
+	DisableJitter      bool           //do not add random jitter to backoff
+	RetryStatusCodes   []int          //retry on these http status codes (default 429,502,503,504)
+	RetryIf            RetryCondition //custom retry condition, overrides the default one
+	AllowNonIdempotent bool           //retry on non-idempotent methods (POST/PATCH/CONNECT) too
+}
+
+// DefaultRetryPolicy returns a retry policy with default settings
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: DEFAULT_RETRY_MAX_ATTEMPTS,
+		MinBackoff:  DEFAULT_RETRY_MIN_BACKOFF,
+		MaxBackoff:  DEFAULT_RETRY_MAX_BACKOFF,
+		RetryStatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// WithRetry set retry policy of client, nil means no retry
+func (c *Client) WithRetry(policy *RetryPolicy) *Client {
+	retry := policy.normalize()
+	c.locker.Lock()
+	c.retry = retry
+	c.locker.Unlock()
+	return c
+}
+
+// normalize copy the policy and fill zero value fields with default
+func (p *RetryPolicy) normalize() *RetryPolicy {
+	if p == nil {
+		return nil
+	}
+	def := DefaultRetryPolicy()
+	policy := *p
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = def.MaxAttempts
+	}
+	if policy.MinBackoff <= 0 {
+		policy.MinBackoff = def.MinBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = def.MaxBackoff
+	}
+	if policy.MaxBackoff < policy.MinBackoff {
+		policy.MaxBackoff = policy.MinBackoff
+	}
+	if len(policy.RetryStatusCodes) == 0 {
+		policy.RetryStatusCodes = def.RetryStatusCodes
+	}
+	return &policy
+}
+
+// enabled check whether the request of method could be retried
+func (p *RetryPolicy) enabled(strMethod string) bool {
+	if p == nil || p.MaxAttempts <= 1 {
+		return false
+	}
+	return p.AllowNonIdempotent || isIdempotentMethod(strMethod)
+}
+
+// shouldRetry check whether the last attempt should be retried
+func (p *RetryPolicy) shouldRetry(ctx context.Context, r *Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if p.RetryIf != nil {
+		return p.RetryIf(r, err)
+	}
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && !isRejectedError(err)
+	}
+	if r == nil {
+		return false
+	}
+	for _, code := range p.RetryStatusCodes {
+		if r.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff calculate the duration to wait before next attempt, the attempt starts from 1
+func (p *RetryPolicy) backoff(attempt int, r *Response) time.Duration {
+	if r != nil {
+		if wait, ok := parseRetryAfter(r.Header.Get(HEADER_KEY_RETRY_AFTER)); ok {
+			if wait > p.MaxBackoff {
+				wait = p.MaxBackoff
+			}
+			return wait
+		}
+	}
+	wait := p.MinBackoff
+	for i := 1; i < attempt && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	if !p.DisableJitter {
+		half := wait / 2
+		wait = half + time.Duration(rand.Int63n(int64(half)+1))
+	}
+	return wait
+}
+
+// parseRetryAfter parse Retry-After header value in delay seconds or http date
+func parseRetryAfter(strValue string) (time.Duration, bool) {
+	if strValue == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(strValue); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(strValue); err == nil {
+		wait := time.Until(t)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// isRejectedError check whether the request was rejected by client before sending, retry would be rejected again
+func isRejectedError(err error) bool {
+	var circuitErr *CircuitOpenError
+	var limitErr *RateLimitError
+	return errors.As(err, &circuitErr) || errors.As(err, &limitErr)
+}
+
+// isIdempotentMethod check whether the http method is idempotent
+func isIdempotentMethod(strMethod string) bool {
+	switch strMethod {
+	case HTTP_METHOD_GET, HTTP_METHOD_HEAD, HTTP_METHOD_OPTIONS, HTTP_METHOD_TRACE, HTTP_METHOD_PUT, HTTP_METHOD_DELETE:
+		return true
+	}
+	return false
+}
+
+// sleepContext sleep for a while or return error when context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
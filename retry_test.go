@@ -0,0 +1,228 @@
+package httpc
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := (&RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, DisableJitter: true}).normalize()
+	var cases = []struct {
+		attempt    int
+		retryAfter string
+		expect     time.Duration
+	}{
+		{1, "", 100 * time.Millisecond},
+		{2, "", 200 * time.Millisecond},
+		{3, "", 400 * time.Millisecond},
+		{4, "", 800 * time.Millisecond},
+		{5, "", time.Second}, //capped to max backoff
+		{60, "", time.Second},
+		{1, "0", 0},
+		{1, "1", time.Second},
+		{1, "120", time.Second}, //Retry-After is capped to max backoff too
+		{3, "invalid", 400 * time.Millisecond},
+		{1, time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	}
+	for _, c := range cases {
+		r := &Response{Header: http.Header{}}
+		if c.retryAfter != "" {
+			r.Header.Set(HEADER_KEY_RETRY_AFTER, c.retryAfter)
+		}
+		if d := p.backoff(c.attempt, r); d != c.expect {
+			t.Errorf("backoff(%d) with Retry-After [%s] = [%v], expect [%v]", c.attempt, c.retryAfter, d, c.expect)
+		}
+	}
+	if d := p.backoff(2, nil); d != 200*time.Millisecond {
+		t.Errorf("backoff(2) without response = [%v], expect [200ms]", d)
+	}
+}
+
+func TestRetryPolicyJitter(t *testing.T) {
+	p := (&RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}).normalize()
+	var cases = []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{10, time.Second},
+	}
+	for _, c := range cases {
+		for i := 0; i < 100; i++ {
+			if d := p.backoff(c.attempt, nil); d < c.max/2 || d > c.max {
+				t.Fatalf("backoff(%d) with jitter = [%v], expect in [%v, %v]", c.attempt, d, c.max/2, c.max)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	var cases = []struct {
+		value  string
+		expect time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"5", 5 * time.Second, true},
+		{"0", 0, true},
+		{"-1", 0, false},
+		{"1.5", 0, false},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, true},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0, true},
+	}
+	for _, c := range cases {
+		if d, ok := parseRetryAfter(c.value); d != c.expect || ok != c.ok {
+			t.Errorf("parseRetryAfter(%q) = (%v, %v), expect (%v, %v)", c.value, d, ok, c.expect, c.ok)
+		}
+	}
+	d, ok := parseRetryAfter(time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat))
+	if !ok || d <= 28*time.Second || d > 30*time.Second {
+		t.Errorf("parseRetryAfter of http date 30s later = (%v, %v), expect about 30s", d, ok)
+	}
+}
+
+func TestRetryPolicyNormalize(t *testing.T) {
+	if p := (*RetryPolicy)(nil).normalize(); p != nil {
+		t.Errorf("normalize nil policy = %+v, expect nil", p)
+	}
+	p := (&RetryPolicy{MinBackoff: time.Minute, MaxBackoff: time.Second}).normalize()
+	if p.MaxAttempts != DEFAULT_RETRY_MAX_ATTEMPTS || p.MaxBackoff != time.Minute || len(p.RetryStatusCodes) == 0 {
+		t.Errorf("normalized policy %+v", p)
+	}
+}
+
+func TestRetryPolicyEnabled(t *testing.T) {
+	var cases = []struct {
+		policy *RetryPolicy
+		method string
+		expect bool
+	}{
+		{nil, HTTP_METHOD_GET, false},
+		{&RetryPolicy{MaxAttempts: 1}, HTTP_METHOD_GET, false},
+		{&RetryPolicy{MaxAttempts: 3}, HTTP_METHOD_GET, true},
+		{&RetryPolicy{MaxAttempts: 3}, HTTP_METHOD_PUT, true},
+		{&RetryPolicy{MaxAttempts: 3}, HTTP_METHOD_DELETE, true},
+		{&RetryPolicy{MaxAttempts: 3}, HTTP_METHOD_POST, false},
+		{&RetryPolicy{MaxAttempts: 3}, HTTP_METHOD_PATCH, false},
+		{&RetryPolicy{MaxAttempts: 3, AllowNonIdempotent: true}, HTTP_METHOD_POST, true},
+	}
+	for _, c := range cases {
+		if ok := c.policy.enabled(c.method); ok != c.expect {
+			t.Errorf("policy %+v enabled(%s) = %v, expect %v", c.policy, c.method, ok, c.expect)
+		}
+	}
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	p := DefaultRetryPolicy()
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	var cases = []struct {
+		name   string
+		ctx    context.Context
+		r      *Response
+		err    error
+		expect bool
+	}{
+		{"503", context.Background(), &Response{StatusCode: http.StatusServiceUnavailable}, nil, true},
+		{"429", context.Background(), &Response{StatusCode: http.StatusTooManyRequests}, nil, true},
+		{"500", context.Background(), &Response{StatusCode: http.StatusInternalServerError}, nil, false},
+		{"200", context.Background(), &Response{StatusCode: http.StatusOK}, nil, false},
+		{"transport error", context.Background(), nil, errors.New("connection reset"), true},
+		{"canceled", context.Background(), nil, context.Canceled, false},
+		{"deadline", context.Background(), nil, context.DeadlineExceeded, false},
+		{"context done", canceled, &Response{StatusCode: http.StatusServiceUnavailable}, nil, false},
+		{"circuit open", context.Background(), nil, &CircuitOpenError{Host: "a.example.com:80"}, false},
+		{"rate limited", context.Background(), nil, &RateLimitError{Host: "a.example.com:80"}, false},
+		{"no response", context.Background(), nil, nil, false},
+	}
+	for _, c := range cases {
+		if ok := p.shouldRetry(c.ctx, c.r, c.err); ok != c.expect {
+			t.Errorf("%s: shouldRetry = %v, expect %v", c.name, ok, c.expect)
+		}
+	}
+	p.RetryIf = func(r *Response, err error) bool {
+		return r != nil && r.StatusCode == http.StatusInternalServerError
+	}
+	if !p.shouldRetry(context.Background(), &Response{StatusCode: http.StatusInternalServerError}, nil) ||
+		p.shouldRetry(context.Background(), &Response{StatusCode: http.StatusServiceUnavailable}, nil) {
+		t.Errorf("RetryIf does not override the default condition")
+	}
+}
+
+// retryServer respond the status codes in order and record the method and body of every request
+type retryServer struct {
+	codes  []int
+	locker sync.Mutex
+	bodies []string
+}
+
+func (s *retryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, _ := ioutil.ReadAll(r.Body)
+	s.locker.Lock()
+	n := len(s.bodies)
+	s.bodies = append(s.bodies, r.Method+" "+string(data))
+	s.locker.Unlock()
+	code := http.StatusOK
+	if n < len(s.codes) {
+		code = s.codes[n]
+	}
+	w.WriteHeader(code)
+}
+
+func TestSendRetry(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	var cases = []struct {
+		name   string
+		client *Client
+		method string
+		codes  []int
+		status int
+		err    interface{} //pointer to the error type expected
+		bodies int         //requests received by server
+	}{
+		{"put resent", NewClient().WithRetry(policy), HTTP_METHOD_PUT,
+			[]int{http.StatusServiceUnavailable}, http.StatusOK, nil, 2},
+		{"exhausted", NewClient().WithRetry(policy), HTTP_METHOD_PUT,
+			[]int{503, 503, 503, 503}, http.StatusServiceUnavailable, nil, 3},
+		{"post not retried", NewClient().WithRetry(policy), HTTP_METHOD_POST,
+			[]int{http.StatusServiceUnavailable}, http.StatusServiceUnavailable, nil, 1},
+		{"circuit open not retried", NewClient().WithRetry(policy).WithCircuitBreaker(&CircuitBreakerPolicy{MinRequests: 1, CoolDown: time.Hour}),
+			HTTP_METHOD_PUT, []int{http.StatusServiceUnavailable}, 0, new(*CircuitOpenError), 1},
+		{"rate limited not retried", NewClient().WithRetry(policy).WithRateLimit(&RateLimitPolicy{Global: &RateLimit{Rate: 0.1}, FailFast: true}),
+			HTTP_METHOD_PUT, []int{http.StatusServiceUnavailable}, 0, new(*RateLimitError), 1},
+	}
+	for _, c := range cases {
+		srv := &retryServer{codes: c.codes}
+		ts := httptest.NewServer(srv)
+		r, err := c.client.SendRequest(nil, c.method, ts.URL, strings.NewReader("payload"))
+		switch {
+		case c.err != nil:
+			if err == nil || !errors.As(err, c.err) {
+				t.Errorf("%s: error [%v], expect %T", c.name, err, c.err)
+			}
+		case err != nil:
+			t.Errorf("%s: error [%s]", c.name, err)
+		case r.StatusCode != c.status:
+			t.Errorf("%s: status [%d], expect [%d]", c.name, r.StatusCode, c.status)
+		}
+		if len(srv.bodies) != c.bodies {
+			t.Errorf("%s: server received [%d] requests, expect [%d]", c.name, len(srv.bodies), c.bodies)
+		}
+		for _, body := range srv.bodies {
+			if body != c.method+" payload" {
+				t.Errorf("%s: server received [%s], expect [%s payload]", c.name, body, c.method)
+			}
+		}
+		ts.Close()
+	}
+}
@@ -0,0 +1,135 @@
+package httpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/civet148/log"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// StreamResponse response of a stream request, the body is not buffered in memory and must be closed by caller
+// NOTE: Option.Timeout of client covers reading of the body too
+type StreamResponse struct {
+	StatusCode  int
+	ContentType string
+	Header      http.Header
+	Body        io.ReadCloser
+}
+
+// SendStream send request to destination host and return the response body as a stream
+func (c *Client) SendStream(header http.Header, strMethod, strUrl string, body io.Reader, queries ...url.Values) (s *StreamResponse, err error) {
+	return c.SendStreamContext(context.Background(), header, strMethod, strUrl, body, queries...)
+}
+
+// SendStreamContext send request to destination host with context and return the response body as a stream,
+// the header is merged over client's headers (the same key of header overrides client's)
+func (c *Client) SendStreamContext(ctx context.Context, header http.Header, strMethod, strUrl string, body io.Reader, queries ...url.Values) (s *StreamResponse, err error) {
+	var r *Response
+	if r, err = c.SendRequestContext(withStreamContext(ctx), c.mergeHeader(header), strMethod, strUrl, body, queries...); err != nil {
+		return nil, err
+	}
+	return newStreamResponse(r), nil
+}
+
+// Stream send request by method specified and return the response body as a stream
+func (r *Request) Stream(strMethod, strUrl string) (s *StreamResponse, err error) {
+	var header http.Header
+	var body io.Reader
+	if header, body, err = r.build(); err != nil {
+		return nil, err
+	}
+	return r.client.SendStreamContext(r.ctx, header, strMethod, MakePathUrl(strUrl, r.pathParams), body, r.queries()...)
+}
+
+func newStreamResponse(r *Response) *StreamResponse {
+	s := &StreamResponse{
+		StatusCode:  r.StatusCode,
+		ContentType: r.ContentType,
+		Header:      r.Header,
+		Body:        r.stream,
+	}
+	if s.Body == nil { //response was replaced by middleware
+		s.Body = ioutil.NopCloser(bytes.NewReader(r.Body))
+	}
+	return s
+}
+
+// Close close the response body stream
+func (s *StreamResponse) Close() error {
+	return s.Body.Close()
+}
+
+// Read read data from response body stream
+func (s *StreamResponse) Read(p []byte) (int, error) {
+	return s.Body.Read(p)
+}
+
+// JsonDecoder returns a json decoder reading from response body stream
+func (s *StreamResponse) JsonDecoder() *json.Decoder {
+	return json.NewDecoder(s.Body)
+}
+
+// DecodeJson decode a json value from response body stream into v
+func (s *StreamResponse) DecodeJson(v interface{}) (err error) {
+	if err = s.JsonDecoder().Decode(v); err != nil {
+		return log.Errorf("decode json stream error [%s]", err)
+	}
+	return nil
+}
+
+// ForEachJson decode json values one by one from response body stream and call fn for each of them.
+// if the stream is a json array, fn will be called for every element of it, otherwise the stream
+// is treated as concatenated or newline delimited json values.
+// returns the error of fn if it's not nil
+func (s *StreamResponse) ForEachJson(fn func(raw json.RawMessage) error) (err error) {
+	reader := bufio.NewReader(s.Body)
+	var isArray bool
+	if isArray, err = isJsonArray(reader); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return log.Errorf("read json stream error [%s]", err)
+	}
+	dec := json.NewDecoder(reader)
+	if isArray {
+		if _, err = dec.Token(); err != nil { // '['
+			return log.Errorf("decode json array start error [%s]", err)
+		}
+	}
+	for dec.More() {
+		var raw json.RawMessage
+		if err = dec.Decode(&raw); err != nil {
+			return log.Errorf("decode json stream error [%s]", err)
+		}
+		if err = fn(raw); err != nil {
+			return err
+		}
+	}
+	if isArray {
+		if _, err = dec.Token(); err != nil { // ']'
+			return log.Errorf("decode json array end error [%s]", err)
+		}
+	}
+	return nil
+}
+
+// isJsonArray skip leading white spaces and check whether the next json value is an array
+func isJsonArray(reader *bufio.Reader) (bool, error) {
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			return false, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = reader.ReadByte()
+		default:
+			return b[0] == '[', nil
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package httpc
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSendStreamClientHeader(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("Authorization") + "|" + r.Header.Get("X-Trace")))
+	}))
+	defer ts.Close()
+
+	c := NewClient().SetHeader("Authorization", "Bearer token").SetHeader("X-Trace", "client")
+	var cases = []struct {
+		header http.Header
+		expect string
+	}{
+		{nil, "Bearer token|client"},
+		{http.Header{"X-Trace": {"request"}}, "Bearer token|request"},
+	}
+	for _, tc := range cases {
+		s, err := c.SendStream(tc.header, HTTP_METHOD_GET, ts.URL, nil)
+		if err != nil {
+			t.Fatalf("send stream error [%s]", err)
+		}
+		data, err := ioutil.ReadAll(s)
+		_ = s.Close()
+		if err != nil || string(data) != tc.expect {
+			t.Errorf("send stream with header %v received [%s] error [%v], expect [%s]", tc.header, data, err, tc.expect)
+		}
+	}
+}
@@ -12,14 +12,19 @@ func init() {
 }
 
 func main() {
-	c := &httpc.Client{}
+	c := httpc.NewClient(&httpc.Option{
+		Timeout: 30,
+		RateLimit: &httpc.RateLimitPolicy{
+			PerHost: &httpc.RateLimit{Rate: 2, Burst: 2}, //avoid being banned by the block explorer
+		},
+	})
 	defer c.Close()
 	//FastJson()
 	FilfoxGet(c)
 }
 
 func FilfoxGet(c *httpc.Client) {
-	c.Header().Set("token", "12345678901234567890")
+	c.SetHeader("token", "12345678901234567890")
 
 	uv := httpc.NewUrlValues()
 	uv.Add("page", 0).Add("pageSize", 15)
@@ -0,0 +1,169 @@
+package httpc
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"github.com/civet148/log"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	PIN_PREFIX_SHA256 = "sha256/"
+)
+
+// TlsOption options to make a tls.Config for private PKI
+type TlsOption struct {
+	CertFile           string   //client certificate PEM file for mutual TLS
+	KeyFile            string   //client private key PEM file for mutual TLS
+	CertPEM            []byte   //client certificate PEM data, used if CertFile is empty
+	KeyPEM             []byte   //client private key PEM data, used if KeyFile is empty
+	CAFiles            []string //CA bundle PEM files to verify server certificate
+	CAPEMs             [][]byte //CA bundle PEM data to verify server certificate
+	WithSystemCAs      bool     //append CAs to the system cert pool instead of using them only
+	ServerName         string   //server name for SNI and verification, default is the host of url
+	InsecureSkipVerify bool     //skip server certificate verification (pins are still checked against the leaf certificate)
+	Pins               []string //SHA-256 of server certificate's SPKI in base64, "sha256/" prefix is optional
+}
+
+// PinError the verified certificate chain of server doesn't match any of the pinned public keys
+type PinError struct {
+	ServerName string   //server name of TLS connection
+	Pins       []string //SPKI pins of the server certificate chain
+}
+
+func (e *PinError) Error() string {
+	return fmt.Sprintf("server [%s] certificate pins %v mismatch", e.ServerName, e.Pins)
+}
+
+// NewTlsConfig make tls.Config by TLS option
+func NewTlsConfig(opt *TlsOption) (conf *tls.Config, err error) {
+	conf = &tls.Config{
+		ServerName:         opt.ServerName,
+		InsecureSkipVerify: opt.InsecureSkipVerify,
+	}
+	var cert tls.Certificate
+	if opt.CertFile != "" || opt.KeyFile != "" {
+		if cert, err = LoadClientCert(opt.CertFile, opt.KeyFile); err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	} else if len(opt.CertPEM) != 0 || len(opt.KeyPEM) != 0 {
+		if cert, err = tls.X509KeyPair(opt.CertPEM, opt.KeyPEM); err != nil {
+			return nil, log.Errorf("parse client certificate PEM error [%s]", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	if len(opt.CAFiles) != 0 || len(opt.CAPEMs) != 0 {
+		var pool *x509.CertPool
+		if opt.WithSystemCAs {
+			if pool, err = x509.SystemCertPool(); err != nil {
+				return nil, log.Errorf("load system cert pool error [%s]", err)
+			}
+		} else {
+			pool = x509.NewCertPool()
+		}
+		if err = appendCAFiles(pool, opt.CAFiles...); err != nil {
+			return nil, err
+		}
+		if err = appendCAPEMs(pool, opt.CAPEMs...); err != nil {
+			return nil, err
+		}
+		conf.RootCAs = pool
+	}
+	if len(opt.Pins) != 0 {
+		conf.VerifyConnection = newPinVerifier(opt.Pins)
+	}
+	return conf, nil
+}
+
+// LoadClientCert load client certificate and private key from PEM files
+func LoadClientCert(strCertFile, strKeyFile string) (cert tls.Certificate, err error) {
+	if cert, err = tls.LoadX509KeyPair(strCertFile, strKeyFile); err != nil {
+		return cert, log.Errorf("load client certificate [%s] key [%s] error [%s]", strCertFile, strKeyFile, err)
+	}
+	return cert, nil
+}
+
+// LoadCACertPool load CA bundles from PEM files into a new cert pool
+func LoadCACertPool(strCAFiles ...string) (pool *x509.CertPool, err error) {
+	pool = x509.NewCertPool()
+	if err = appendCAFiles(pool, strCAFiles...); err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// NewCACertPool load CA bundles from PEM data into a new cert pool
+func NewCACertPool(pems ...[]byte) (pool *x509.CertPool, err error) {
+	pool = x509.NewCertPool()
+	if err = appendCAPEMs(pool, pems...); err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// SPKIPin returns the base64 SHA-256 pin of certificate's SubjectPublicKeyInfo with "sha256/" prefix
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return PIN_PREFIX_SHA256 + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func appendCAFiles(pool *x509.CertPool, strCAFiles ...string) error {
+	for _, strFile := range strCAFiles {
+		data, err := ioutil.ReadFile(strFile)
+		if err != nil {
+			return log.Errorf("read CA file [%s] error [%s]", strFile, err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return log.Errorf("no certificate found in CA file [%s]", strFile)
+		}
+	}
+	return nil
+}
+
+func appendCAPEMs(pool *x509.CertPool, pems ...[]byte) error {
+	for i, data := range pems {
+		if !pool.AppendCertsFromPEM(data) {
+			return log.Errorf("no certificate found in CA PEM data #%d", i)
+		}
+	}
+	return nil
+}
+
+// newPinVerifier returns a tls.Config.VerifyConnection which checks any certificate of the verified chains matches one
+// of the pins. the certificates sent by server but not in a verified chain are never trusted, so if verification was
+// skipped only the leaf certificate is checked
+func newPinVerifier(pins []string) func(cs tls.ConnectionState) error {
+	var pinned = make(map[string]bool)
+	for _, pin := range pins {
+		pinned[PIN_PREFIX_SHA256+strings.TrimPrefix(strings.TrimSpace(pin), PIN_PREFIX_SHA256)] = true
+	}
+	return func(cs tls.ConnectionState) error {
+		var chains = cs.VerifiedChains
+		if len(chains) == 0 && len(cs.PeerCertificates) != 0 {
+			chains = [][]*x509.Certificate{cs.PeerCertificates[:1]}
+		}
+		var got []string
+		var seen = make(map[string]bool)
+		for _, chain := range chains {
+			for _, cert := range chain {
+				pin := SPKIPin(cert)
+				if pinned[pin] {
+					return nil
+				}
+				if !seen[pin] {
+					seen[pin] = true
+					got = append(got, pin)
+				}
+			}
+		}
+		return &PinError{
+			ServerName: cs.ServerName,
+			Pins:       got,
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package httpc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testServerName = "pin.example.com"
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+// newTestCert make a certificate signed by parent, it's self-signed if parent is nil
+func newTestCert(t *testing.T, strName string, isCA bool, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: strName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if !isCA {
+		template.DNSNames = []string{testServerName}
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// newTLSTestServer start a TLS server which sends leaf and the extra certificates as its chain
+func newTLSTestServer(leaf *testCert, extra ...*testCert) *httptest.Server {
+	chain := [][]byte{leaf.cert.Raw}
+	for _, c := range extra {
+		chain = append(chain, c.cert.Raw)
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	ts.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: chain, PrivateKey: leaf.key}}}
+	ts.StartTLS()
+	return ts
+}
+
+func TestNewTlsConfigPins(t *testing.T) {
+	realCA := newTestCert(t, "real ca", true, nil)
+	realLeaf := newTestCert(t, testServerName, false, realCA)
+	rogueCA := newTestCert(t, "rogue ca", true, nil) //a CA trusted by client which misissued a certificate for the host
+	rogueLeaf := newTestCert(t, testServerName, false, rogueCA)
+	selfSigned := newTestCert(t, testServerName, false, nil)
+
+	var cases = []struct {
+		name   string
+		server *httptest.Server
+		opt    TlsOption
+		pinErr bool
+	}{
+		{"leaf pinned", newTLSTestServer(realLeaf),
+			TlsOption{CAPEMs: [][]byte{realCA.pem}, Pins: []string{SPKIPin(realLeaf.cert)}}, false},
+		{"ca pinned", newTLSTestServer(realLeaf),
+			TlsOption{CAPEMs: [][]byte{realCA.pem}, Pins: []string{" " + SPKIPin(realCA.cert)[len(PIN_PREFIX_SHA256):]}}, false},
+		{"mismatch", newTLSTestServer(realLeaf),
+			TlsOption{CAPEMs: [][]byte{realCA.pem}, Pins: []string{SPKIPin(rogueCA.cert)}}, true},
+		{"misissued leaf with pinned cert appended", newTLSTestServer(rogueLeaf, rogueCA, realLeaf),
+			TlsOption{CAPEMs: [][]byte{realCA.pem, rogueCA.pem}, Pins: []string{SPKIPin(realLeaf.cert)}}, true},
+		{"insecure leaf pinned", newTLSTestServer(realLeaf),
+			TlsOption{InsecureSkipVerify: true, Pins: []string{SPKIPin(realLeaf.cert)}}, false},
+		{"insecure bogus leaf with pinned cert appended", newTLSTestServer(selfSigned, realLeaf),
+			TlsOption{InsecureSkipVerify: true, Pins: []string{SPKIPin(realLeaf.cert)}}, true},
+	}
+	for _, c := range cases {
+		c.opt.ServerName = testServerName
+		conf, err := NewTlsConfig(&c.opt)
+		if err != nil {
+			t.Fatalf("%s: new tls config error [%s]", c.name, err)
+		}
+		cli := &http.Client{Transport: &http.Transport{TLSClientConfig: conf}}
+		resp, err := cli.Get(c.server.URL)
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		var pinErr *PinError
+		if c.pinErr != errors.As(err, &pinErr) {
+			t.Errorf("%s: request error [%v], expect pin error [%v]", c.name, err, c.pinErr)
+		}
+		if c.pinErr && pinErr != nil && pinErr.ServerName != testServerName {
+			t.Errorf("%s: pin error server name [%s], expect [%s]", c.name, pinErr.ServerName, testServerName)
+		}
+		c.server.Close()
+	}
+}
+
+func TestNewTlsConfigPEM(t *testing.T) {
+	ca := newTestCert(t, "ca", true, nil)
+	client := newTestCert(t, "client", false, ca)
+	der, err := x509.MarshalECPrivateKey(client.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+
+	conf, err := NewTlsConfig(&TlsOption{CertPEM: client.pem, KeyPEM: keyPEM, CAPEMs: [][]byte{ca.pem}})
+	if err != nil {
+		t.Fatalf("new tls config error [%s]", err)
+	}
+	if len(conf.Certificates) != 1 || conf.RootCAs == nil || conf.VerifyConnection != nil {
+		t.Errorf("tls config certificates [%d] root CAs [%v] pin verifier [%v]", len(conf.Certificates), conf.RootCAs != nil, conf.VerifyConnection != nil)
+	}
+	var cases = []struct {
+		name string
+		opt  TlsOption
+	}{
+		{"bad CA PEM", TlsOption{CAPEMs: [][]byte{[]byte("not a certificate")}}},
+		{"mismatched key", TlsOption{CertPEM: client.pem, KeyPEM: ca.pem}},
+		{"missing CA file", TlsOption{CAFiles: []string{"/nonexistent/ca.pem"}}},
+		{"missing cert file", TlsOption{CertFile: "/nonexistent/cert.pem", KeyFile: "/nonexistent/key.pem"}},
+	}
+	for _, c := range cases {
+		if _, err = NewTlsConfig(&c.opt); err == nil {
+			t.Errorf("%s: new tls config succeeded, expect error", c.name)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package httpc
+
+import (
+	"crypto/tls"
+	"golang.org/x/net/http/httpproxy"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ProxyFunc select proxy for request, nil url means no proxy (same as http.Transport.Proxy)
+type ProxyFunc func(req *http.Request) (*url.URL, error)
+
+const (
+	DEFAULT_DIAL_TIMEOUT            = 30 * time.Second
+	DEFAULT_KEEP_ALIVE              = 30 * time.Second
+	DEFAULT_MAX_IDLE_CONNS          = 100
+	DEFAULT_MAX_IDLE_CONNS_PER_HOST = 32
+	DEFAULT_IDLE_CONN_TIMEOUT       = 90 * time.Second
+	DEFAULT_TLS_HANDSHAKE_TIMEOUT   = 10 * time.Second
+	DEFAULT_EXPECT_CONTINUE_TIMEOUT = 1 * time.Second
+)
+
+// newTransport make http transport by option, the settings not specified are cloned from http.DefaultTransport
+func newTransport(opt *Option) *http.Transport {
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if ok {
+		transport = transport.Clone()
+	} else {
+		transport = &http.Transport{
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          DEFAULT_MAX_IDLE_CONNS,
+			IdleConnTimeout:       DEFAULT_IDLE_CONN_TIMEOUT,
+			TLSHandshakeTimeout:   DEFAULT_TLS_HANDSHAKE_TIMEOUT,
+			ExpectContinueTimeout: DEFAULT_EXPECT_CONTINUE_TIMEOUT,
+		}
+	}
+	dialer := &net.Dialer{
+		Timeout:   DEFAULT_DIAL_TIMEOUT,
+		KeepAlive: DEFAULT_KEEP_ALIVE,
+	}
+	if opt.DialTimeout > 0 {
+		dialer.Timeout = opt.DialTimeout
+	}
+	if opt.KeepAlive != 0 {
+		dialer.KeepAlive = opt.KeepAlive
+	}
+	transport.DialContext = newDialFunc(opt, dialer)
+	transport.Proxy = withoutUnixProxy(newProxyFunc(opt))
+	transport.TLSClientConfig = opt.TlsConf
+	if opt.InsecureSkipVerify {
+		if transport.TLSClientConfig != nil {
+			transport.TLSClientConfig = transport.TLSClientConfig.Clone()
+		} else {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.InsecureSkipVerify = true
+	}
+	if opt.ClientCertProvider != nil {
+		transport.TLSClientConfig = withCertProvider(transport.TLSClientConfig, opt.ClientCertProvider)
+	}
+	transport.MaxIdleConnsPerHost = DEFAULT_MAX_IDLE_CONNS_PER_HOST
+
+	if opt.MaxIdleConns > 0 {
+		transport.MaxIdleConns = opt.MaxIdleConns
+	}
+	if opt.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = opt.MaxIdleConnsPerHost
+	}
+	if opt.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = opt.MaxConnsPerHost
+	}
+	if opt.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = opt.IdleConnTimeout
+	}
+	if opt.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = opt.TLSHandshakeTimeout
+	}
+	if opt.ResponseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = opt.ResponseHeaderTimeout
+	}
+	if opt.ExpectContinueTimeout > 0 {
+		transport.ExpectContinueTimeout = opt.ExpectContinueTimeout
+	}
+	if opt.DisableKeepAlives {
+		transport.DisableKeepAlives = true
+	}
+	if opt.DisableHTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(authority string, c *tls.Conn) http.RoundTripper)
+	}
+	return transport
+}
+
+// requestTimeout returns the whole request timeout of option
+func requestTimeout(opt *Option) time.Duration {
+	if opt.RequestTimeout > 0 {
+		return opt.RequestTimeout
+	}
+	return time.Duration(opt.Timeout) * time.Second
+}
+
+// newProxyFunc make proxy function by option, the priority is DisableProxy/UnixSocket > ProxyFunc > Proxy > environment
+func newProxyFunc(opt *Option) ProxyFunc {
+	if opt.DisableProxy || opt.UnixSocket != "" {
+		return nil
+	}
+	if opt.ProxyFunc != nil {
+		return opt.ProxyFunc
+	}
+	if opt.Proxy != "" {
+		conf := &httpproxy.Config{
+			HTTPProxy:  opt.Proxy,
+			HTTPSProxy: opt.Proxy,
+			NoProxy:    opt.NoProxy,
+		}
+		proxy := conf.ProxyFunc()
+		return func(req *http.Request) (*url.URL, error) {
+			return proxy(req.URL)
+		}
+	}
+	return http.ProxyFromEnvironment
+}
+
+// ProxyByHost returns a proxy function which selects proxy url by request host (without port),
+// the "*" key matches any other hosts, empty proxy url means connect directly
+func ProxyByHost(proxies map[string]string) ProxyFunc {
+	return func(req *http.Request) (*url.URL, error) {
+		strProxy, ok := proxies[req.URL.Hostname()]
+		if !ok {
+			strProxy = proxies["*"]
+		}
+		if strProxy == "" {
+			return nil, nil
+		}
+		return url.Parse(strProxy)
+	}
+}
@@ -0,0 +1,158 @@
+package httpc
+
+import (
+	"github.com/civet148/log"
+	"net/url"
+	"strings"
+)
+
+// WithBaseUrl set base url of client, relative url of every request will be resolved against it
+func (c *Client) WithBaseUrl(strBaseUrl string) *Client {
+	c.locker.Lock()
+	c.baseUrl = strBaseUrl
+	c.locker.Unlock()
+	return c
+}
+
+// WithPathParams set default path parameters of client, e.g. {"addr":"f07749"} for url "/address/{addr}/blocks"
+func (c *Client) WithPathParams(params map[string]string) *Client {
+	c.locker.Lock()
+	if c.pathParams == nil {
+		c.pathParams = make(map[string]string)
+	}
+	for k, v := range params {
+		c.pathParams[k] = v
+	}
+	c.locker.Unlock()
+	return c
+}
+
+// WithSortedQuery sort all query parameters by key for deterministic ordering (e.g. signing)
+func (c *Client) WithSortedQuery(sorted bool) *Client {
+	c.locker.Lock()
+	c.sortQuery = sorted
+	c.locker.Unlock()
+	return c
+}
+
+// SetPathParam set a path parameter of request, the {key} in url will be replaced by escaped value
+func (r *Request) SetPathParam(key, value string) *Request {
+	r.pathParams[key] = value
+	return r
+}
+
+// SetPathParams set path parameters of request, the {key} in url will be replaced by escaped value
+func (r *Request) SetPathParams(params map[string]string) *Request {
+	for k, v := range params {
+		r.pathParams[k] = v
+	}
+	return r
+}
+
+// MakePathUrl replace {key} placeholders in url template with path escaped values,
+// the placeholders without value will be kept
+func MakePathUrl(strTemplate string, params map[string]string) string {
+	if len(params) == 0 || !strings.Contains(strTemplate, "{") {
+		return strTemplate
+	}
+	var sb strings.Builder
+	for {
+		start := strings.Index(strTemplate, "{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(strTemplate[start:], "}")
+		if end < 0 {
+			break
+		}
+		end += start
+		sb.WriteString(strTemplate[:start])
+		key := strTemplate[start+1 : end]
+		if value, ok := params[key]; ok {
+			sb.WriteString(url.PathEscape(value))
+		} else {
+			sb.WriteString(strTemplate[start : end+1])
+		}
+		strTemplate = strTemplate[end+1:]
+	}
+	sb.WriteString(strTemplate)
+	return sb.String()
+}
+
+// resolveUrl replace path parameters of client and join the relative url to client's base url
+func (c *Client) resolveUrl(strUrl string) string {
+	c.locker.RLock()
+	strBaseUrl := c.baseUrl
+	strUrl = MakePathUrl(strUrl, c.pathParams)
+	c.locker.RUnlock()
+	return rewriteUnixUrl(joinBaseUrl(strBaseUrl, strUrl))
+}
+
+// joinBaseUrl join relative url to base url, the absolute url will be returned directly
+func joinBaseUrl(strBaseUrl, strUrl string) string {
+	if strBaseUrl == "" || isAbsoluteUrl(strUrl) {
+		return strUrl
+	}
+	if strUrl == "" {
+		return strBaseUrl
+	}
+	if strings.HasPrefix(strUrl, "?") || strings.HasPrefix(strUrl, "#") {
+		return strBaseUrl + strUrl
+	}
+	return strings.TrimRight(strBaseUrl, "/") + "/" + strings.TrimLeft(strUrl, "/")
+}
+
+// isAbsoluteUrl check whether the url has a scheme like http://, https:// or unix://
+func isAbsoluteUrl(strUrl string) bool {
+	if strings.HasPrefix(strUrl, URL_SCHEME_UNIX+"://") {
+		return true
+	}
+	u, err := url.Parse(strUrl)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+// MakeQueryUrl merge queries into url, the existing query string and fragment of url will be kept.
+// if sorted is true, the existing query will be parsed and all parameters will be encoded sorted by key,
+// otherwise the existing query is kept as it is and queries are appended in order.
+// the existing query which could not be parsed is never sorted, it's kept as it is with a warning log
+func MakeQueryUrl(strUrl string, sorted bool, queries ...url.Values) string {
+	var strFragment string
+	if idx := strings.Index(strUrl, "#"); idx >= 0 {
+		strUrl, strFragment = strUrl[:idx], strUrl[idx:]
+	}
+	var strQuery string
+	if idx := strings.Index(strUrl, "?"); idx >= 0 {
+		strUrl, strQuery = strUrl[:idx], strUrl[idx+1:]
+	}
+	var params []string
+	if sorted {
+		values, err := url.ParseQuery(strQuery)
+		if err != nil {
+			log.Warnf("parse query [%s] of url [%s] error [%s], keep it unsorted", strQuery, strUrl, err)
+			sorted = false
+		} else {
+			for _, query := range queries {
+				for k, vs := range query {
+					values[k] = append(values[k], vs...)
+				}
+			}
+			if encoded := values.Encode(); encoded != "" {
+				params = append(params, encoded)
+			}
+		}
+	}
+	if !sorted {
+		if strQuery != "" {
+			params = append(params, strQuery)
+		}
+		for _, query := range queries { //URL路径查询参数
+			if encoded := query.Encode(); encoded != "" {
+				params = append(params, encoded)
+			}
+		}
+	}
+	if len(params) != 0 {
+		strUrl += "?" + strings.Join(params, "&")
+	}
+	return strUrl + strFragment
+}
@@ -0,0 +1,75 @@
+package httpc
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestMakePathUrl(t *testing.T) {
+	var cases = []struct {
+		template string
+		params   map[string]string
+		expect   string
+	}{
+		{"/address/{addr}/blocks", map[string]string{"addr": "f07749"}, "/address/f07749/blocks"},
+		{"/address/{addr}/blocks", nil, "/address/{addr}/blocks"},
+		{"/files/{name}", map[string]string{"name": "a b/c"}, "/files/a%20b%2Fc"},
+		{"/{a}/{b}/{c}", map[string]string{"a": "1", "c": "3"}, "/1/{b}/3"},
+		{"/{a}{b}", map[string]string{"a": "1", "b": "2"}, "/12"},
+		{"/users/{id", map[string]string{"id": "1"}, "/users/{id"},
+		{"/users", map[string]string{"id": "1"}, "/users"},
+	}
+	for _, c := range cases {
+		if strUrl := MakePathUrl(c.template, c.params); strUrl != c.expect {
+			t.Errorf("MakePathUrl(%q, %v) = %q, expect %q", c.template, c.params, strUrl, c.expect)
+		}
+	}
+}
+
+func TestJoinBaseUrl(t *testing.T) {
+	var cases = []struct {
+		base   string
+		url    string
+		expect string
+	}{
+		{"", "/users", "/users"},
+		{"https://api.example.com", "/users", "https://api.example.com/users"},
+		{"https://api.example.com/", "users", "https://api.example.com/users"},
+		{"https://api.example.com/v1/", "/users", "https://api.example.com/v1/users"},
+		{"https://api.example.com/v1", "", "https://api.example.com/v1"},
+		{"https://api.example.com/v1", "?page=1", "https://api.example.com/v1?page=1"},
+		{"https://api.example.com/v1", "#top", "https://api.example.com/v1#top"},
+		{"https://api.example.com", "http://other.example.com/users", "http://other.example.com/users"},
+		{"https://api.example.com", "unix:///var/run/docker.sock:/info", "unix:///var/run/docker.sock:/info"},
+	}
+	for _, c := range cases {
+		if strUrl := joinBaseUrl(c.base, c.url); strUrl != c.expect {
+			t.Errorf("joinBaseUrl(%q, %q) = %q, expect %q", c.base, c.url, strUrl, c.expect)
+		}
+	}
+}
+
+func TestMakeQueryUrl(t *testing.T) {
+	var cases = []struct {
+		url     string
+		sorted  bool
+		queries []url.Values
+		expect  string
+	}{
+		{"/users", false, nil, "/users"},
+		{"/users", false, []url.Values{{"b": {"2"}}, {"a": {"1"}}}, "/users?b=2&a=1"},
+		{"/users?z=9", false, []url.Values{{"a": {"1"}}}, "/users?z=9&a=1"},
+		{"/users?z=9#top", false, []url.Values{{"a": {"1"}}}, "/users?z=9&a=1#top"},
+		{"/users?z=9&b=2", true, []url.Values{{"a": {"1"}}}, "/users?a=1&b=2&z=9"},
+		{"/users?a=2", true, []url.Values{{"a": {"1"}}}, "/users?a=2&a=1"},
+		{"/users#top", true, []url.Values{{"b": {"2"}, "a": {"1"}}}, "/users?a=1&b=2#top"},
+		{"/users?", true, nil, "/users"},
+		{"/users?z=%zz&b=2", true, []url.Values{{"a": {"1"}}}, "/users?z=%zz&b=2&a=1"}, //malformed query is kept unsorted
+		{"/users?z=%zz", false, nil, "/users?z=%zz"},
+	}
+	for _, c := range cases {
+		if strUrl := MakeQueryUrl(c.url, c.sorted, c.queries...); strUrl != c.expect {
+			t.Errorf("MakeQueryUrl(%q, %v, %v) = %q, expect %q", c.url, c.sorted, c.queries, strUrl, c.expect)
+		}
+	}
+}
@@ -5,4 +5,4 @@ import "encoding/base64"
 func basicAuth(username, password string) string {
 	auth := username + ":" + password
 	return base64.StdEncoding.EncodeToString([]byte(auth))
-}
\ No newline at end of file
+}